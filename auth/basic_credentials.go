@@ -0,0 +1,47 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"net/http"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HostCredentialsBasic is a HostCredentials implementation that represents
+// a username and password pair, to be sent to the server using the HTTP
+// "Basic" authentication scheme.
+type HostCredentialsBasic struct {
+	Username string
+	Password string
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsBasic does not fully implement these interfaces.
+var _ HostCredentials = HostCredentialsBasic{}
+var _ HostCredentialsWritable = HostCredentialsBasic{}
+
+// PrepareRequest alters the given HTTP request by setting its Authorization
+// header to use the "Basic" scheme with the encapsulated username and
+// password.
+func (bc HostCredentialsBasic) PrepareRequest(req *http.Request) {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.SetBasicAuth(bc.Username, bc.Password)
+}
+
+// Token returns the password, which is the closest analog to an
+// authentication token for this credentials type.
+func (bc HostCredentialsBasic) Token() string {
+	return bc.Password
+}
+
+// ToStore returns a credentials object with the attributes "username" and
+// "password".
+func (bc HostCredentialsBasic) ToStore() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"username": cty.StringVal(bc.Username),
+		"password": cty.StringVal(bc.Password),
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestHostCredentialsBasic(t *testing.T) {
+	creds := HostCredentialsBasic{
+		Username: "alfred",
+		Password: "hunter2",
+	}
+
+	{
+		req := &http.Request{}
+		creds.PrepareRequest(req)
+		authStr := req.Header.Get("authorization")
+		if got, want := authStr, "Basic YWxmcmVkOmh1bnRlcjI="; got != want {
+			t.Errorf("wrong Authorization header value %q; want %q", got, want)
+		}
+	}
+
+	{
+		got := creds.ToStore()
+		want := cty.ObjectVal(map[string]cty.Value{
+			"username": cty.StringVal("alfred"),
+			"password": cty.StringVal("hunter2"),
+		})
+		if !want.RawEquals(got) {
+			t.Errorf("wrong storable object value\ngot:  %#v\nwant: %#v", got, want)
+		}
+	}
+}
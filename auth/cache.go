@@ -3,47 +3,89 @@
 package auth
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	svchost "github.com/hashicorp/terraform-svchost"
 )
 
+// CredentialsInvalidator is implemented by credentials sources that keep
+// credentials in memory, such as CachingCredentialsSource, to allow callers
+// to discard the in-memory credentials for a host when a server rejects them.
+//
+// Unlike ForgetForHost, invalidation does not affect any underlying
+// persistent storage, so the next lookup for the host will return whatever
+// is currently stored there.
+type CredentialsInvalidator interface {
+	// InvalidateForHost discards any in-memory credentials for the given
+	// host. It does nothing if there are none.
+	InvalidateForHost(host svchost.Hostname)
+}
+
+// InvalidateForHost discards any in-memory credentials for the given host
+// from the given source, if it implements CredentialsInvalidator. It does
+// nothing for other sources.
+func InvalidateForHost(source CredentialsSource, host svchost.Hostname) {
+	if inv, ok := source.(CredentialsInvalidator); ok {
+		inv.InvalidateForHost(host)
+	}
+}
+
 // CachingCredentialsSource creates a new credentials source that wraps another
 // and caches its results in memory, on a per-hostname basis.
 //
-// No means is provided for expiration of cached credentials, so a caching
-// credentials source should have a limited lifetime (one Terraform operation,
-// for example) to ensure that time-limited credentials don't expire before
-// their cache entries do.
+// Cached credentials that implement ExpiringHostCredentials are discarded
+// once their expiry time has passed. No other means is provided for
+// expiration of cached credentials, so a caching credentials source should
+// have a limited lifetime (one Terraform operation, for example) to ensure
+// that other time-limited credentials don't expire before their cache entries
+// do. Use RefreshingCredentialsSource to obtain new credentials before
+// they expire.
 func CachingCredentialsSource(source CredentialsSource) CredentialsSource {
 	return &cachingCredentialsSource{
-		source: source,
-		cache:  map[svchost.Hostname]HostCredentials{},
+		source:     source,
+		cache:      map[svchost.Hostname]HostCredentials{},
+		scopeCache: map[CredentialsScope]HostCredentials{},
+		now:        time.Now,
 	}
 }
 
 type cachingCredentialsSource struct {
-	source CredentialsSource
-	cache  map[svchost.Hostname]HostCredentials
-	mu     sync.Mutex
+	source     CredentialsSource
+	cache      map[svchost.Hostname]HostCredentials
+	scopeCache map[CredentialsScope]HostCredentials
+	mu         sync.Mutex
+
+	// now is overridden during tests to simulate the passage of time.
+	now func() time.Time
 }
 
 // ForHost passes the given hostname on to the wrapped credentials source and
 // caches the result to return for future requests with the same hostname.
 //
-// Both credentials and non-credentials (nil) responses are cached.
+// Both credentials and non-credentials (nil) responses are cached, until
+// the cached credentials expire.
 //
 // No cache entry is created if the wrapped source returns an error, to allow
 // the caller to retry the failing operation.
 func (s *cachingCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForHostContext(context.Background(), host)
+}
+
+// ForHostContext is like ForHost, but uses CredentialsForHostContext to
+// obtain credentials from the wrapped source if they are not cached.
+func (s *cachingCredentialsSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
 	s.mu.Lock()
 	if cache, cached := s.cache[host]; cached {
-		s.mu.Unlock()
-		return cache, nil
+		if expiry, ok := credentialsExpiry(cache); !ok || s.now().Before(expiry) {
+			s.mu.Unlock()
+			return cache, nil
+		}
 	}
 	s.mu.Unlock()
 
-	result, err := s.source.ForHost(host)
+	result, err := CredentialsForHostContext(ctx, s.source, host)
 	if err != nil {
 		return result, err
 	}
@@ -54,12 +96,36 @@ func (s *cachingCredentialsSource) ForHost(host svchost.Hostname) (HostCredentia
 	return result, nil
 }
 
+// ForScope is like ForHost, but uses CredentialsForScope to look up
+// credentials for the given scope in the wrapped source, and caches the
+// result for the scope.
+func (s *cachingCredentialsSource) ForScope(scope CredentialsScope) (HostCredentials, error) {
+	s.mu.Lock()
+	if cache, cached := s.scopeCache[scope]; cached {
+		if expiry, ok := credentialsExpiry(cache); !ok || s.now().Before(expiry) {
+			s.mu.Unlock()
+			return cache, nil
+		}
+	}
+	s.mu.Unlock()
+
+	result, err := CredentialsForScope(s.source, scope)
+	if err != nil {
+		return result, err
+	}
+
+	s.mu.Lock()
+	s.scopeCache[scope] = result
+	s.mu.Unlock()
+	return result, nil
+}
+
 func (s *cachingCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
 	// We'll delete the cache entry even if the store fails, since that just
 	// means that the next read will go to the real store and get a chance to
 	// see which object (old or new) is actually present.
 	s.mu.Lock()
-	delete(s.cache, host)
+	s.forgetInternal(host)
 	s.mu.Unlock()
 	return s.source.StoreForHost(host, credentials)
 }
@@ -69,7 +135,27 @@ func (s *cachingCredentialsSource) ForgetForHost(host svchost.Hostname) error {
 	// means that the next read will go to the real store and get a chance to
 	// see if the object is still present.
 	s.mu.Lock()
-	delete(s.cache, host)
+	s.forgetInternal(host)
 	s.mu.Unlock()
 	return s.source.ForgetForHost(host)
 }
+
+// InvalidateForHost discards the cache entry for the given host, and also
+// invalidates the wrapped source.
+func (s *cachingCredentialsSource) InvalidateForHost(host svchost.Hostname) {
+	s.mu.Lock()
+	s.forgetInternal(host)
+	s.mu.Unlock()
+	InvalidateForHost(s.source, host)
+}
+
+// forgetInternal deletes all of the cache entries for the given host,
+// including those for scopes on that host. The caller must hold s.mu.
+func (s *cachingCredentialsSource) forgetInternal(host svchost.Hostname) {
+	delete(s.cache, host)
+	for scope := range s.scopeCache {
+		if scope.Host == host {
+			delete(s.scopeCache, scope)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes that a server may return in the "error" parameter of a Bearer
+// challenge, as defined in IETF RFC 6750 section 3.1.
+const (
+	BearerErrorInvalidRequest    = "invalid_request"
+	BearerErrorInvalidToken      = "invalid_token"
+	BearerErrorInsufficientScope = "insufficient_scope"
+)
+
+// AuthChallenge represents a single authentication challenge from a
+// WWW-Authenticate response header, as defined in IETF RFC 7235 section 4.1.
+type AuthChallenge struct {
+	// Scheme is the authentication scheme, such as "Bearer", exactly as
+	// given by the server. Schemes are case-insensitive, so use IsScheme
+	// to compare them.
+	Scheme string
+
+	// Params are the challenge's authentication parameters, with the
+	// parameter names converted to lowercase.
+	Params map[string]string
+
+	// Token68 is the challenge's token68 value, for the rare schemes that use
+	// one instead of authentication parameters.
+	Token68 string
+}
+
+// IsScheme returns true if the receiver's scheme matches the given scheme,
+// ignoring case.
+func (c AuthChallenge) IsScheme(scheme string) bool {
+	return strings.EqualFold(c.Scheme, scheme)
+}
+
+// BearerError returns the "error" parameter of a Bearer challenge, such as
+// BearerErrorInvalidToken, or an empty string if the challenge is not for the
+// Bearer scheme or has no error code.
+func (c AuthChallenge) BearerError() string {
+	if !c.IsScheme("Bearer") {
+		return ""
+	}
+	return c.Params["error"]
+}
+
+// ChallengesFromResponse parses all of the WWW-Authenticate headers in the
+// given response.
+func ChallengesFromResponse(resp *http.Response) ([]AuthChallenge, error) {
+	return ParseWWWAuthenticate(resp.Header.Values("WWW-Authenticate")...)
+}
+
+// ParseWWWAuthenticate parses the given WWW-Authenticate header values, each
+// of which may contain one or more comma-separated challenges, and returns
+// all of the challenges in the order given.
+func ParseWWWAuthenticate(values ...string) ([]AuthChallenge, error) {
+	var ret []AuthChallenge
+	for _, v := range values {
+		p := challengeParser{s: v}
+		challenges, err := p.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid WWW-Authenticate header: %w", err)
+		}
+		ret = append(ret, challenges...)
+	}
+	return ret, nil
+}
+
+// challengeParser is a parser for the challenge syntax from IETF RFC 7235.
+type challengeParser struct {
+	s   string
+	pos int
+}
+
+func (p *challengeParser) parse() ([]AuthChallenge, error) {
+	var ret []AuthChallenge
+	for {
+		p.skip(" \t,")
+		if p.done() {
+			return ret, nil
+		}
+
+		scheme := p.token()
+		if scheme == "" {
+			return nil, fmt.Errorf("expected authentication scheme at offset %d", p.pos)
+		}
+		challenge := AuthChallenge{
+			Scheme: scheme,
+			Params: map[string]string{},
+		}
+		p.skip(" \t")
+
+		if p.paramAhead() {
+			if err := p.params(challenge.Params); err != nil {
+				return nil, err
+			}
+		} else {
+			challenge.Token68 = p.token68()
+		}
+		ret = append(ret, challenge)
+
+		p.skip(" \t")
+		if !p.done() && p.s[p.pos] != ',' {
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.s[p.pos], p.pos)
+		}
+	}
+}
+
+// params parses a comma-separated list of authentication parameters into
+// the given map, stopping at the start of the next challenge, if any.
+func (p *challengeParser) params(into map[string]string) error {
+	for {
+		name := p.token()
+		p.skip(" \t")
+		p.pos++ // the "=", which paramAhead already checked for
+		p.skip(" \t")
+
+		var value string
+		if !p.done() && p.s[p.pos] == '"' {
+			var err error
+			if value, err = p.quotedString(); err != nil {
+				return err
+			}
+		} else {
+			value = p.token()
+		}
+		into[strings.ToLower(name)] = value
+
+		p.skip(" \t")
+		if p.done() || p.s[p.pos] != ',' {
+			return nil
+		}
+		start := p.pos
+		p.skip(" \t,")
+		if !p.paramAhead() {
+			// The next item is the start of a new challenge, so we'll
+			// leave its preceding comma for the caller.
+			p.pos = start
+			return nil
+		}
+	}
+}
+
+// paramAhead returns true if the remaining input begins with an
+// authentication parameter, as opposed to a token68 value or the scheme
+// of another challenge.
+func (p *challengeParser) paramAhead() bool {
+	i := p.pos
+	for i < len(p.s) && isTokenChar(p.s[i]) {
+		i++
+	}
+	if i == p.pos {
+		return false
+	}
+	for i < len(p.s) && (p.s[i] == ' ' || p.s[i] == '\t') {
+		i++
+	}
+	if i >= len(p.s) || p.s[i] != '=' {
+		return false
+	}
+	i++
+	for i < len(p.s) && (p.s[i] == ' ' || p.s[i] == '\t') {
+		i++
+	}
+	// A token68 value may end with "=" padding, which we must not mistake
+	// for a parameter with an empty value.
+	return i < len(p.s) && p.s[i] != '=' && p.s[i] != ','
+}
+
+func (p *challengeParser) token() string {
+	start := p.pos
+	for !p.done() && isTokenChar(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *challengeParser) token68() string {
+	start := p.pos
+	for !p.done() && isToken68Char(p.s[p.pos]) {
+		p.pos++
+	}
+	for !p.done() && p.s[p.pos] == '=' {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *challengeParser) quotedString() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	var buf strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return buf.String(), nil
+		case '\\':
+			if p.done() {
+				return "", fmt.Errorf("unterminated quoted string at offset %d", start)
+			}
+			buf.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted string at offset %d", start)
+}
+
+func (p *challengeParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+func (p *challengeParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+// isTokenChar returns true if c is a "tchar" as defined in IETF RFC 7230
+// section 3.2.6.
+func isTokenChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1
+}
+
+// isToken68Char returns true if c is valid in a "token68" as defined in
+// IETF RFC 7235 section 2.1, excluding the trailing "=" padding.
+func isToken68Char(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("-._~+/", c) != -1
+}
@@ -0,0 +1,131 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseWWWAuthenticate(t *testing.T) {
+	tests := map[string]struct {
+		values  []string
+		want    []AuthChallenge
+		wantErr bool
+	}{
+		"none": {
+			nil,
+			nil,
+			false,
+		},
+		"bearer with error": {
+			[]string{`Bearer realm="example", error="invalid_token", error_description="The access token expired"`},
+			[]AuthChallenge{
+				{
+					Scheme: "Bearer",
+					Params: map[string]string{
+						"realm":             "example",
+						"error":             "invalid_token",
+						"error_description": "The access token expired",
+					},
+				},
+			},
+			false,
+		},
+		"multiple challenges in one header": {
+			[]string{`Newauth realm="apps", type=1, title="Login to \"apps\"", Basic realm="simple"`},
+			[]AuthChallenge{
+				{
+					Scheme: "Newauth",
+					Params: map[string]string{
+						"realm": "apps",
+						"type":  "1",
+						"title": `Login to "apps"`,
+					},
+				},
+				{
+					Scheme: "Basic",
+					Params: map[string]string{
+						"realm": "simple",
+					},
+				},
+			},
+			false,
+		},
+		"multiple headers": {
+			[]string{`Basic realm="simple"`, `bearer scope="read write", ERROR=insufficient_scope`},
+			[]AuthChallenge{
+				{
+					Scheme: "Basic",
+					Params: map[string]string{"realm": "simple"},
+				},
+				{
+					Scheme: "bearer",
+					Params: map[string]string{
+						"scope": "read write",
+						"error": "insufficient_scope",
+					},
+				},
+			},
+			false,
+		},
+		"scheme only": {
+			[]string{`Bearer, Negotiate`},
+			[]AuthChallenge{
+				{Scheme: "Bearer", Params: map[string]string{}},
+				{Scheme: "Negotiate", Params: map[string]string{}},
+			},
+			false,
+		},
+		"token68": {
+			[]string{`Negotiate YWJjZA==, Bearer error="invalid_token"`},
+			[]AuthChallenge{
+				{Scheme: "Negotiate", Params: map[string]string{}, Token68: "YWJjZA=="},
+				{Scheme: "Bearer", Params: map[string]string{"error": "invalid_token"}},
+			},
+			false,
+		},
+		"unterminated quoted string": {
+			[]string{`Bearer realm="example`},
+			nil,
+			true,
+		},
+		"missing scheme": {
+			[]string{`="example"`},
+			nil,
+			true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseWWWAuthenticate(test.values...)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("succeeded with %#v; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAuthChallengeBearerError(t *testing.T) {
+	challenges, err := ParseWWWAuthenticate(`Basic error="invalid_token", BEARER error="insufficient_scope"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := challenges[0].BearerError(); got != "" {
+		t.Errorf("wrong error for Basic challenge %q; want none", got)
+	}
+	if got, want := challenges[1].BearerError(), BearerErrorInsufficientScope; got != want {
+		t.Errorf("wrong error for Bearer challenge %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// clientAssertionType is the client assertion type for JWT client
+// authentication, as defined in IETF RFC 7523 section 2.2.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionLifetime is how long a client assertion JWT remains valid.
+const clientAssertionLifetime = 5 * time.Minute
+
+// ClientCredentialsConfig is the configuration for
+// ClientCredentialsSource.
+type ClientCredentialsConfig struct {
+	// ClientID is the client identifier, to be used as "client_id" in
+	// requests to the token endpoint.
+	ClientID string
+
+	// ClientSecret is the client secret, used to authenticate the client
+	// with HTTP Basic authentication as described in IETF RFC 6749 section
+	// 2.3.1. Exactly one of ClientSecret and PrivateKey must be set.
+	ClientSecret string
+
+	// PrivateKey is the client's private key, used to sign a JWT client
+	// assertion as described in IETF RFC 7523 section 2.2. Exactly one of
+	// ClientSecret and PrivateKey must be set. RSA keys must be at least
+	// 2048 bits.
+	PrivateKey crypto.Signer
+
+	// KeyID is the identifier of PrivateKey that the server knows it by, if
+	// any, to be included in the client assertion header as "kid".
+	KeyID string
+
+	// SigningAlgorithm is the algorithm used to sign the client assertion,
+	// which may be any supported by JWKS.VerifyJWT. If it is empty, RS256 is
+	// used for RSA keys and the algorithm matching the curve is used for
+	// elliptic curve keys.
+	SigningAlgorithm string
+
+	// TokenURL is the URL of the token endpoint to use for all hosts. If it
+	// is nil, TokenURLForHost is used to find the endpoint for each host.
+	TokenURL *url.URL
+
+	// TokenURLForHost returns the URL of the token endpoint to use for the
+	// given host, or nil if the host does not support the client credentials
+	// grant. For example, disco.Disco.ClientCredentialsTokenURL finds the
+	// endpoint using service discovery. It is called only for hosts that
+	// match Hosts.
+	//
+	// Whichever way it is found, the token endpoint must use HTTPS.
+	TokenURLForHost func(host svchost.Hostname) (*url.URL, error)
+
+	// Hosts are the hostnames or wildcard host patterns, as described for
+	// StaticCredentialsSource, for which the source will obtain tokens. The
+	// source has no credentials for any other host. At least one is
+	// required, so that the client's secret or signed assertion is never
+	// sent to a token endpoint advertised by an arbitrary host.
+	Hosts []svchost.Hostname
+
+	// Scopes are the scopes to request, if any.
+	Scopes []string
+
+	// HTTPClient is the client to use for requests to the token endpoint. If
+	// it is nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+type clientCredentialsSource struct {
+	config ClientCredentialsConfig
+	tokens *tokenCache
+}
+
+// ClientCredentialsSource returns a CredentialsSource that obtains
+// credentials for each host from a token endpoint using the OAuth client
+// credentials grant, as defined in IETF RFC 6749 section 4.4, for use by
+// machine users that have their own OAuth client.
+//
+// As with TokenExchangeCredentialsSource, the resulting access tokens are
+// returned as HostCredentialsOAuth2 values and cached in memory until shortly
+// before they expire, and credentials can't be stored in or forgotten from
+// this source.
+//
+// An error is returned if the configuration is invalid.
+func ClientCredentialsSource(config ClientCredentialsConfig) (CredentialsSource, error) {
+	if config.ClientID == "" {
+		return nil, errors.New("client ID is required")
+	}
+	if (config.ClientSecret == "") == (config.PrivateKey == nil) {
+		return nil, errors.New("exactly one of client secret and private key is required")
+	}
+	if len(config.Hosts) == 0 {
+		return nil, errors.New("at least one host is required")
+	}
+	if config.PrivateKey != nil && config.SigningAlgorithm == "" {
+		alg, err := jwtAlgorithmForKey(config.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		config.SigningAlgorithm = alg
+	}
+
+	return &clientCredentialsSource{
+		config: config,
+		tokens: newTokenCache(),
+	}, nil
+}
+
+var _ CredentialsInvalidator = (*clientCredentialsSource)(nil)
+
+func (s *clientCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForHostContext(context.Background(), host)
+}
+
+// ForHostContext implements ContextCredentialsSource, aborting any request
+// to the token endpoint if the given context is cancelled.
+func (s *clientCredentialsSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	if !hostInPatterns(host, s.config.Hosts) {
+		return nil, nil
+	}
+	return s.tokens.get(ctx, host, func() (HostCredentials, error) {
+		return s.requestToken(ctx, host)
+	})
+}
+
+func (s *clientCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	return fmt.Errorf("can't store new credentials in a client credentials source")
+}
+
+func (s *clientCredentialsSource) ForgetForHost(host svchost.Hostname) error {
+	return fmt.Errorf("can't discard credentials from a client credentials source")
+}
+
+// InvalidateForHost discards the cached token for the given host, so that
+// the next request for credentials obtains a new token.
+func (s *clientCredentialsSource) InvalidateForHost(host svchost.Hostname) {
+	s.tokens.invalidate(host)
+}
+
+func (s *clientCredentialsSource) requestToken(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	endpoint := s.config.TokenURL
+	if endpoint == nil && s.config.TokenURLForHost != nil {
+		var err error
+		endpoint, err = s.config.TokenURLForHost(host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find token endpoint for %s: %w", host.ForDisplay(), err)
+		}
+	}
+	if endpoint == nil {
+		return nil, nil
+	}
+
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+	}
+	if len(s.config.Scopes) != 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	var clientAuth func(*http.Request)
+	if s.config.PrivateKey != nil {
+		assertion, err := s.clientAssertion(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_id", s.config.ClientID)
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	} else {
+		clientAuth = func(req *http.Request) {
+			// The client ID and secret must be form-encoded before being
+			// used as the username and password, as described in IETF
+			// RFC 6749 section 2.3.1.
+			req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+		}
+	}
+
+	log.Printf("[DEBUG] Requesting client credentials token for %s from %s", host, endpoint)
+	creds, err := requestToken(ctx, s.config.HTTPClient, endpoint, form, clientAuth)
+	if err != nil {
+		return nil, fmt.Errorf("client credentials grant for %s failed: %w", host.ForDisplay(), err)
+	}
+	return creds, nil
+}
+
+// clientAssertion returns a newly-signed JWT client assertion for use with
+// the given token endpoint.
+func (s *clientCredentialsSource) clientAssertion(endpoint *url.URL) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return signJWT(s.config.SigningAlgorithm, s.config.KeyID, s.config.PrivateKey, map[string]any{
+		"iss": s.config.ClientID,
+		"sub": s.config.ClientID,
+		"aud": endpoint.String(),
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+	})
+}
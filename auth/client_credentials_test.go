@@ -0,0 +1,176 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestClientCredentialsSource(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := ParseJWKS([]byte(testJWKSJSON(t, rsaKey, ecKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if got, want := r.FormValue("grant_type"), "client_credentials"; got != want {
+			t.Errorf("wrong grant_type %q; want %q", got, want)
+		}
+		if got, want := r.FormValue("scope"), "deploy"; got != want {
+			t.Errorf("wrong scope %q; want %q", got, want)
+		}
+
+		authenticated := false
+		if id, secret, ok := r.BasicAuth(); ok {
+			authenticated = id == "machine%3Auser" && secret == "s3cret"
+		} else if r.FormValue("client_assertion_type") == "urn:ietf:params:oauth:client-assertion-type:jwt-bearer" {
+			claims, err := jwks.VerifyJWT(r.FormValue("client_assertion"))
+			authenticated = err == nil &&
+				claims.Issuer == "machine:user" &&
+				claims.Subject == "machine:user" &&
+				len(claims.Audience) == 1 && claims.Audience[0] == "https://"+r.Host+"/token" &&
+				r.FormValue("client_id") == "machine:user"
+		}
+		if !authenticated {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"machine-token","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+	tokenURL, err := url.Parse(server.URL + "/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]ClientCredentialsConfig{
+		"client secret": {
+			ClientSecret: "s3cret",
+		},
+		"RSA private key": {
+			PrivateKey: rsaKey,
+			KeyID:      "rsa",
+		},
+		"elliptic curve private key": {
+			PrivateKey: ecKey,
+		},
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			requests = 0
+			config.ClientID = "machine:user"
+			config.TokenURL = tokenURL
+			config.Hosts = []svchost.Hostname{"example.com"}
+			config.Scopes = []string{"deploy"}
+			config.HTTPClient = server.Client()
+			src, err := ClientCredentialsSource(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				creds, err := src.ForHost("example.com")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if creds == nil || creds.Token() != "machine-token" {
+					t.Fatalf("wrong credentials %#v", creds)
+				}
+			}
+			if got, want := requests, 1; got != want {
+				t.Errorf("wrong number of token requests %d; want %d", got, want)
+			}
+
+			creds, err := src.ForHost("example.net")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds != nil {
+				t.Errorf("got credentials for unconfigured host; want nil")
+			}
+		})
+	}
+
+	t.Run("wrong secret", func(t *testing.T) {
+		src, err := ClientCredentialsSource(ClientCredentialsConfig{
+			ClientID:     "machine:user",
+			ClientSecret: "wrong",
+			TokenURL:     tokenURL,
+			Hosts:        []svchost.Hostname{"example.com"},
+			Scopes:       []string{"deploy"},
+			HTTPClient:   server.Client(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := src.ForHost("example.com"); err == nil {
+			t.Errorf("completed successfully; want error")
+		}
+	})
+	t.Run("insecure token endpoint", func(t *testing.T) {
+		insecure := *tokenURL
+		insecure.Scheme = "http"
+		src, err := ClientCredentialsSource(ClientCredentialsConfig{
+			ClientID:        "machine:user",
+			ClientSecret:    "s3cret",
+			TokenURLForHost: func(host svchost.Hostname) (*url.URL, error) { return &insecure, nil },
+			Hosts:           []svchost.Hostname{"example.com"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = 0
+		_, err = src.ForHost("example.com")
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if got, want := err.Error(), "must use https"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want message containing %q", got, want)
+		}
+		if requests != 0 {
+			t.Errorf("sent request to insecure token endpoint")
+		}
+	})
+	t.Run("invalid config", func(t *testing.T) {
+		configs := map[string]ClientCredentialsConfig{
+			"secret and private key": {
+				ClientID:     "machine:user",
+				ClientSecret: "s3cret",
+				PrivateKey:   rsaKey,
+				Hosts:        []svchost.Hostname{"example.com"},
+			},
+			"no hosts": {
+				ClientID:        "machine:user",
+				ClientSecret:    "s3cret",
+				TokenURLForHost: func(host svchost.Hostname) (*url.URL, error) { return tokenURL, nil },
+			},
+		}
+		for name, config := range configs {
+			if _, err := ClientCredentialsSource(config); err == nil {
+				t.Errorf("%s: completed successfully; want error", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,50 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// ContextCredentialsSource is an optional extension of CredentialsSource for
+// sources that may do slow work to obtain credentials, such as running a
+// helper program or making a network request, and that can stop that work
+// early when a context is cancelled.
+type ContextCredentialsSource interface {
+	CredentialsSource
+
+	// ForHostContext is like ForHost, but returns early with an error if
+	// the given context is cancelled or its deadline passes first.
+	ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error)
+}
+
+// CredentialsForHostContext obtains credentials for the given host from the
+// given source, using ForHostContext if the source implements
+// ContextCredentialsSource.
+//
+// For other sources, this returns the context's error without consulting
+// the source if the context is already done, and otherwise calls ForHost,
+// which will not be interrupted if the context is cancelled.
+func CredentialsForHostContext(ctx context.Context, source CredentialsSource, host svchost.Hostname) (HostCredentials, error) {
+	if cs, ok := source.(ContextCredentialsSource); ok {
+		return cs.ForHostContext(ctx, host)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return source.ForHost(host)
+}
+
+// ForHostContext is like ForHost, but uses CredentialsForHostContext to
+// obtain credentials from each source in turn.
+func (c Credentials) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	for _, source := range c {
+		creds, err := CredentialsForHostContext(ctx, source, host)
+		if creds != nil || err != nil {
+			return creds, err
+		}
+	}
+	return nil, nil
+}
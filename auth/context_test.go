@@ -0,0 +1,58 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestCredentialsForHostContext(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	src := StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+		host: {"token": "abc123"},
+	})
+
+	t.Run("live context", func(t *testing.T) {
+		creds, err := CredentialsForHostContext(context.Background(), src, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds, HostCredentialsToken("abc123"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		creds, err := CredentialsForHostContext(ctx, src, host)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("wrong error %v; want context.Canceled", err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials; want nil")
+		}
+	})
+	t.Run("through wrappers", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		wrapped := CachingCredentialsSource(Credentials{src})
+		if _, err := CredentialsForHostContext(ctx, wrapped, host); !errors.Is(err, context.Canceled) {
+			t.Fatalf("wrong error %v; want context.Canceled", err)
+		}
+
+		// The failed lookup must not have been cached.
+		creds, err := CredentialsForHostContext(context.Background(), wrapped, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds, HostCredentialsToken("abc123"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+}
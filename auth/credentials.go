@@ -101,10 +101,17 @@ func (c Credentials) ForHost(host svchost.Hostname) (HostCredentials, error) {
 
 // StoreForHost passes the given arguments to the same operation on the
 // first CredentialsSource in the receiver.
+//
+// An error is returned without consulting any source if the given hostname
+// is a wildcard host pattern covering an entire public suffix, such as
+// "*.com".
 func (c Credentials) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
 	if len(c) == 0 {
 		return fmt.Errorf("no credentials store is available")
 	}
+	if err := checkStoreHost(host); err != nil {
+		return err
+	}
 
 	return c[0].StoreForHost(host, credentials)
 }
@@ -118,3 +125,12 @@ func (c Credentials) ForgetForHost(host svchost.Hostname) error {
 
 	return c[0].ForgetForHost(host)
 }
+
+// InvalidateForHost passes the given hostname to the same operation on
+// each of the CredentialsSource objects in the receiver that implement
+// CredentialsInvalidator.
+func (c Credentials) InvalidateForHost(host svchost.Hostname) {
+	for _, source := range c {
+		InvalidateForHost(source, host)
+	}
+}
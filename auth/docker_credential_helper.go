@@ -0,0 +1,134 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// dockerCredentialsNotFound is the message that Docker credential helpers
+// print when they have no credentials for a requested server URL.
+const dockerCredentialsNotFound = "credentials not found in native keychain"
+
+// dockerTokenUsername is the username that Docker uses for credentials that
+// consist only of a token.
+const dockerTokenUsername = "<token>"
+
+type dockerCredentialHelperSource struct {
+	program helperProgram
+}
+
+// dockerCredentials is the JSON representation of credentials in the Docker
+// credential helper protocol.
+type dockerCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// DockerCredentialHelperSource returns a CredentialsSource that runs the
+// given program, which must implement the Docker credential helper protocol,
+// in order to obtain credentials. Such programs are conventionally named
+// with the prefix "docker-credential-".
+//
+// As with HelperProgramCredentialsSource, the given executable path must be
+// an absolute path, and this function will panic if it is not.
+//
+// Each hostname is passed to the helper as a server URL consisting of the
+// "https" scheme and the hostname in ASCII compatibility form (punycode
+// form), such as "https://example.com". The Secret returned by the helper
+// is used as a bearer token, as for HostCredentialsToken, and its Username
+// is ignored.
+//
+// Credentials stored using this source must have a non-empty token, which
+// is saved as the Secret along with the username "<token>".
+func DockerCredentialHelperSource(executable string, args ...string) CredentialsSource {
+	if !filepath.IsAbs(executable) {
+		panic("DockerCredentialHelperSource requires absolute path to executable")
+	}
+
+	return &dockerCredentialHelperSource{
+		program: newHelperProgram(executable, args),
+	}
+}
+
+func (s *dockerCredentialHelperSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForHostContext(context.Background(), host)
+}
+
+// ForHostContext implements ContextCredentialsSource, terminating the helper
+// program if the given context is cancelled.
+func (s *dockerCredentialHelperSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	out, err := s.program.run(ctx, []byte(dockerServerURL(host)), "get")
+	if isDockerCredentialsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var creds dockerCredentials
+	err = json.Unmarshal(out, &creds)
+	if err != nil {
+		return nil, fmt.Errorf("malformed output from %s: %s", s.program.executable, err)
+	}
+	if creds.Secret == "" {
+		return nil, nil
+	}
+
+	return HostCredentialsToken(creds.Secret), nil
+}
+
+func (s *dockerCredentialHelperSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	if err := checkStoreHost(host); err != nil {
+		return err
+	}
+
+	token := credentials.Token()
+	if token == "" {
+		return fmt.Errorf("can't store credentials without a token in %s", s.program.executable)
+	}
+
+	toStoreRaw, err := json.Marshal(dockerCredentials{
+		ServerURL: dockerServerURL(host),
+		Username:  dockerTokenUsername,
+		Secret:    token,
+	})
+	if err != nil {
+		return fmt.Errorf("can't serialize credentials to store: %s", err)
+	}
+
+	_, err = s.program.run(context.Background(), toStoreRaw, "store")
+	return err
+}
+
+func (s *dockerCredentialHelperSource) ForgetForHost(host svchost.Hostname) error {
+	_, err := s.program.run(context.Background(), []byte(dockerServerURL(host)), "erase")
+	if isDockerCredentialsNotFound(err) {
+		// There is nothing to forget, so we have already succeeded.
+		return nil
+	}
+	return err
+}
+
+// dockerServerURL returns the server URL that represents the given hostname
+// in the Docker credential helper protocol.
+func dockerServerURL(host svchost.Hostname) string {
+	return "https://" + string(host)
+}
+
+// isDockerCredentialsNotFound returns true if the given error is from a
+// Docker credential helper reporting that it has no credentials.
+func isDockerCredentialsNotFound(err error) bool {
+	helperErr, ok := err.(*helperProgramError)
+	if !ok {
+		return false
+	}
+	return strings.TrimSpace(helperErr.stdout) == dockerCredentialsNotFound ||
+		strings.TrimSpace(helperErr.stderr) == dockerCredentialsNotFound
+}
@@ -0,0 +1,88 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestDockerCredentialHelperSource(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program := filepath.Join(wd, "testdata", "docker-credential-test")
+	t.Logf("testing with helper at %s", program)
+
+	src := DockerCredentialHelperSource(program)
+
+	t.Run("happy path", func(t *testing.T) {
+		creds, err := src.ForHost(svchost.Hostname("example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds, HostCredentialsToken("example-token"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+	t.Run("no credentials", func(t *testing.T) {
+		creds, err := src.ForHost(svchost.Hostname("nothing.example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials; want nil")
+		}
+	})
+	t.Run("lookup error", func(t *testing.T) {
+		_, err := src.ForHost(svchost.Hostname("fail.example.com"))
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if got, want := err.Error(), "failing because you told me to fail"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want message containing %q", got, want)
+		}
+	})
+	t.Run("store happy path", func(t *testing.T) {
+		err := src.StoreForHost(svchost.Hostname("example.com"), HostCredentialsToken("example-token"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("store error", func(t *testing.T) {
+		err := src.StoreForHost(svchost.Hostname("fail.example.com"), HostCredentialsToken("example-token"))
+		if err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+	t.Run("store without token", func(t *testing.T) {
+		err := src.StoreForHost(svchost.Hostname("example.com"), HostCredentialsHeaders{"X-Api-Key": "abc"})
+		if err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+	t.Run("forget happy path", func(t *testing.T) {
+		err := src.ForgetForHost(svchost.Hostname("example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("forget no credentials", func(t *testing.T) {
+		err := src.ForgetForHost(svchost.Hostname("nothing.example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("forget error", func(t *testing.T) {
+		err := src.ForgetForHost(svchost.Hostname("fail.example.com"))
+		if err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// expiresAtAttr is the name of the attribute used to persist the expiry time
+// of credentials, as an RFC 3339 timestamp.
+const expiresAtAttr = "expires_at"
+
+// ExpiringHostCredentials is an optional extension of HostCredentials for
+// credentials that are valid only until a particular time.
+//
+// Credentials sources and wrappers that are aware of expiry, such as
+// CachingCredentialsSource and RefreshingCredentialsSource, use this to
+// decide when credentials must be obtained again.
+type ExpiringHostCredentials interface {
+	HostCredentials
+
+	// ExpiresAt returns the time after which the credentials are no longer
+	// valid. A zero time means that the expiry time is not known, in which
+	// case the credentials are treated as if they never expire.
+	ExpiresAt() time.Time
+}
+
+// HostCredentialsExpiring is a HostCredentialsWritable implementation that
+// annotates some other writable credentials with an expiry time.
+//
+// The expiry time is saved in the "expires_at" attribute of the object
+// returned from ToStore, and HostCredentialsFromMap and
+// HostCredentialsFromObject will produce a HostCredentialsExpiring when
+// they encounter that attribute alongside any other recognized credentials.
+type HostCredentialsExpiring struct {
+	Credentials HostCredentialsWritable
+	Expiry      time.Time
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsExpiring does not fully implement these interfaces.
+var _ ExpiringHostCredentials = HostCredentialsExpiring{}
+var _ HostCredentialsWritable = HostCredentialsExpiring{}
+
+// PrepareRequest applies the wrapped credentials to the given request.
+func (ec HostCredentialsExpiring) PrepareRequest(req *http.Request) {
+	ec.Credentials.PrepareRequest(req)
+}
+
+// Token returns the authentication token of the wrapped credentials.
+func (ec HostCredentialsExpiring) Token() string {
+	return ec.Credentials.Token()
+}
+
+// ExpiresAt returns the expiry time.
+func (ec HostCredentialsExpiring) ExpiresAt() time.Time {
+	return ec.Expiry
+}
+
+// ToStore returns the storable object of the wrapped credentials with an
+// additional "expires_at" attribute, whose value is the expiry time as an
+// RFC 3339 timestamp.
+func (ec HostCredentialsExpiring) ToStore() cty.Value {
+	inner := ec.Credentials.ToStore()
+	if ec.Expiry.IsZero() {
+		return inner
+	}
+
+	attrs := inner.AsValueMap()
+	if attrs == nil {
+		attrs = make(map[string]cty.Value, 1)
+	}
+	attrs[expiresAtAttr] = cty.StringVal(ec.Expiry.UTC().Format(time.RFC3339))
+	return cty.ObjectVal(attrs)
+}
+
+// credentialsExpiry returns the expiry time of the given credentials, if
+// they implement ExpiringHostCredentials and have a known expiry time.
+func credentialsExpiry(creds HostCredentials) (time.Time, bool) {
+	ec, ok := creds.(ExpiringHostCredentials)
+	if !ok {
+		return time.Time{}, false
+	}
+	expiry := ec.ExpiresAt()
+	return expiry, !expiry.IsZero()
+}
+
+// withStoredExpiry wraps the given credentials in HostCredentialsExpiring
+// if the given raw expiry value is a valid RFC 3339 timestamp, and the
+// credentials are writable and not already aware of their own expiry.
+func withStoredExpiry(creds HostCredentials, raw string) HostCredentials {
+	if creds == nil || raw == "" {
+		return creds
+	}
+	if _, ok := creds.(ExpiringHostCredentials); ok {
+		return creds
+	}
+	writable, ok := creds.(HostCredentialsWritable)
+	if !ok {
+		return creds
+	}
+	expiry, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		// We ignore malformed values in the same way we'd ignore any other
+		// unrecognized attribute.
+		return creds
+	}
+	return HostCredentialsExpiring{
+		Credentials: writable,
+		Expiry:      expiry,
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestHostCredentialsExpiring(t *testing.T) {
+	expiry := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	creds := HostCredentialsExpiring{
+		Credentials: HostCredentialsToken("foo-bar"),
+		Expiry:      expiry,
+	}
+
+	if got, want := creds.Token(), "foo-bar"; got != want {
+		t.Errorf("wrong token %q; want %q", got, want)
+	}
+
+	stored := creds.ToStore()
+	want := cty.ObjectVal(map[string]cty.Value{
+		"token":      cty.StringVal("foo-bar"),
+		"expires_at": cty.StringVal("2025-06-01T12:00:00Z"),
+	})
+	if !want.RawEquals(stored) {
+		t.Fatalf("wrong storable object value\ngot:  %#v\nwant: %#v", stored, want)
+	}
+
+	if got := HostCredentialsFromObject(stored); !cmp.Equal(got, HostCredentials(creds)) {
+		t.Errorf("wrong result from object\n%s", cmp.Diff(HostCredentials(creds), got))
+	}
+
+	m := map[string]interface{}{
+		"token":      "foo-bar",
+		"expires_at": "2025-06-01T12:00:00Z",
+	}
+	if got := HostCredentialsFromMap(m); !cmp.Equal(got, HostCredentials(creds)) {
+		t.Errorf("wrong result from map\n%s", cmp.Diff(HostCredentials(creds), got))
+	}
+
+	m["expires_at"] = "not a timestamp"
+	if got, want := HostCredentialsFromMap(m), HostCredentials(HostCredentialsToken("foo-bar")); got != want {
+		t.Errorf("wrong result for invalid expiry %#v; want %#v", got, want)
+	}
+}
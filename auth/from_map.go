@@ -11,40 +11,186 @@ import (
 // helper) into a HostCredentials object if possible, or returns nil if
 // no credentials could be extracted from the map.
 //
+// If the map has a string "type" key naming a type registered with
+// RegisterCredentialsType then only the decoder for that type is consulted.
+// Otherwise, including for any other "type", the decoders registered with
+// RegisterCredentialsKey are consulted in order of registration. The
+// built-in credentials kinds are, in order of precedence:
+//
+//   - "token" and "refresh_token" together: an OAuth2 access token and
+//     refresh token, returned as HostCredentialsOAuth2.
+//   - "token": a bearer token, returned as HostCredentialsToken.
+//   - "username" and "password" together: returned as HostCredentialsBasic.
+//   - "headers": a map of header names to string values, returned as
+//     HostCredentialsHeaders.
+//
+// If the map also has an "expires_at" key whose value is an RFC 3339
+// timestamp, the result is wrapped in HostCredentialsExpiring.
+//
 // This function ignores map keys it is unfamiliar with, to allow for future
 // expansion of the credentials map format for new credential types.
 func HostCredentialsFromMap(m map[string]interface{}) HostCredentials {
 	if m == nil {
 		return nil
 	}
-	if token, ok := m["token"].(string); ok {
-		return HostCredentialsToken(token)
-	}
-	return nil
+	creds := registry.fromMap(m)
+	expiresAt, _ := m[expiresAtAttr].(string)
+	return withStoredExpiry(creds, expiresAt)
 }
 
 // HostCredentialsFromObject converts a cty.Value of an object type into a
 // HostCredentials object if possible, or returns nil if no credentials could
 // be extracted from the map.
 //
+// The recognized attributes and their precedence, including the optional
+// "expires_at" attribute, are the same as for HostCredentialsFromMap.
+//
 // This function ignores object attributes it is unfamiliar with, to allow for
 // future expansion of the credentials object structure for new credential types.
 //
 // If the given value is not of an object type, this function will panic.
 func HostCredentialsFromObject(obj cty.Value) HostCredentials {
-	if !obj.Type().HasAttribute("token") {
+	if !obj.Type().IsObjectType() {
+		panic("HostCredentialsFromObject requires an object value")
+	}
+	creds := registry.fromObject(obj)
+	expiresAt, _ := stringAttr(obj, expiresAtAttr)
+	return withStoredExpiry(creds, expiresAt)
+}
+
+// tokenDecoder is the HostCredentialsDecoder for HostCredentialsToken.
+type tokenDecoder struct{}
+
+func (tokenDecoder) FromMap(m map[string]interface{}) HostCredentials {
+	if token, ok := m["token"].(string); ok {
+		return HostCredentialsToken(token)
+	}
+	return nil
+}
+
+func (tokenDecoder) FromObject(obj cty.Value) HostCredentials {
+	if token, ok := stringAttr(obj, "token"); ok {
+		return HostCredentialsToken(token)
+	}
+	return nil
+}
+
+// basicDecoder is the HostCredentialsDecoder for HostCredentialsBasic.
+type basicDecoder struct{}
+
+func (basicDecoder) FromMap(m map[string]interface{}) HostCredentials {
+	username, ok := m["username"].(string)
+	if !ok {
+		return nil
+	}
+	password, ok := m["password"].(string)
+	if !ok {
+		return nil
+	}
+	return HostCredentialsBasic{
+		Username: username,
+		Password: password,
+	}
+}
+
+func (basicDecoder) FromObject(obj cty.Value) HostCredentials {
+	username, ok := stringAttr(obj, "username")
+	if !ok {
+		return nil
+	}
+	password, ok := stringAttr(obj, "password")
+	if !ok {
 		return nil
 	}
+	return HostCredentialsBasic{
+		Username: username,
+		Password: password,
+	}
+}
 
-	tokenV := obj.GetAttr("token")
-	if tokenV.IsNull() || !tokenV.IsKnown() {
+// headersDecoder is the HostCredentialsDecoder for HostCredentialsHeaders.
+type headersDecoder struct{}
+
+func (headersDecoder) FromMap(m map[string]interface{}) HostCredentials {
+	if headers := headersFromMapValue(m["headers"]); headers != nil {
+		return headers
+	}
+	return nil
+}
+
+func (headersDecoder) FromObject(obj cty.Value) HostCredentials {
+	if !obj.Type().HasAttribute("headers") {
 		return nil
 	}
-	if !cty.String.Equals(tokenV.Type()) {
+	if headers := headersFromValue(obj.GetAttr("headers")); headers != nil {
+		return headers
+	}
+	return nil
+}
+
+// stringAttr returns the value of the given attribute of the given object
+// if it is present, known, non-null, and of string type.
+func stringAttr(obj cty.Value, name string) (string, bool) {
+	if !obj.Type().HasAttribute(name) {
+		return "", false
+	}
+
+	v := obj.GetAttr(name)
+	if v.IsNull() || !v.IsKnown() {
+		return "", false
+	}
+	if !cty.String.Equals(v.Type()) {
 		// Weird, but maybe some future Terraform version accepts an object
 		// here for some reason, so we'll be resilient.
+		return "", false
+	}
+
+	return v.AsString(), true
+}
+
+// headersFromMapValue interprets the given raw value as a set of headers,
+// returning nil if it is not a map whose values are all strings.
+func headersFromMapValue(raw interface{}) HostCredentialsHeaders {
+	switch v := raw.(type) {
+	case map[string]string:
+		headers := make(HostCredentialsHeaders, len(v))
+		for name, value := range v {
+			headers[name] = value
+		}
+		return headers
+	case map[string]interface{}:
+		headers := make(HostCredentialsHeaders, len(v))
+		for name, rawValue := range v {
+			value, ok := rawValue.(string)
+			if !ok {
+				return nil
+			}
+			headers[name] = value
+		}
+		return headers
+	default:
 		return nil
 	}
+}
 
-	return HostCredentialsToken(tokenV.AsString())
+// headersFromValue interprets the given cty value as a set of headers,
+// returning nil if it is not a map or object whose elements are all strings.
+func headersFromValue(v cty.Value) HostCredentialsHeaders {
+	if v.IsNull() || !v.IsWhollyKnown() {
+		return nil
+	}
+	ty := v.Type()
+	if !ty.IsMapType() && !ty.IsObjectType() {
+		return nil
+	}
+
+	headers := make(HostCredentialsHeaders, v.LengthInt())
+	for it := v.ElementIterator(); it.Next(); {
+		k, ev := it.Element()
+		if ev.IsNull() || !cty.String.Equals(ev.Type()) {
+			return nil
+		}
+		headers[k.AsString()] = ev.AsString()
+	}
+	return headers
 }
@@ -0,0 +1,150 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestHostCredentialsFromMap(t *testing.T) {
+	tests := map[string]struct {
+		m    map[string]interface{}
+		want HostCredentials
+	}{
+		"nil": {
+			nil,
+			nil,
+		},
+		"empty": {
+			map[string]interface{}{},
+			nil,
+		},
+		"token": {
+			map[string]interface{}{"token": "abc123"},
+			HostCredentialsToken("abc123"),
+		},
+		"token takes precedence": {
+			map[string]interface{}{
+				"token":    "abc123",
+				"username": "alfred",
+				"password": "hunter2",
+			},
+			HostCredentialsToken("abc123"),
+		},
+		"basic": {
+			map[string]interface{}{
+				"username": "alfred",
+				"password": "hunter2",
+			},
+			HostCredentialsBasic{Username: "alfred", Password: "hunter2"},
+		},
+		"username without password": {
+			map[string]interface{}{"username": "alfred"},
+			nil,
+		},
+		"headers": {
+			map[string]interface{}{
+				"headers": map[string]interface{}{"X-Api-Key": "abc123"},
+			},
+			HostCredentialsHeaders{"X-Api-Key": "abc123"},
+		},
+		"headers with non-string value": {
+			map[string]interface{}{
+				"headers": map[string]interface{}{"X-Api-Key": 12},
+			},
+			nil,
+		},
+		"unknown keys": {
+			map[string]interface{}{"certificate": "..."},
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := HostCredentialsFromMap(test.m)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHostCredentialsFromObject(t *testing.T) {
+	tests := map[string]struct {
+		obj  cty.Value
+		want HostCredentials
+	}{
+		"empty": {
+			cty.EmptyObjectVal,
+			nil,
+		},
+		"token": {
+			cty.ObjectVal(map[string]cty.Value{
+				"token": cty.StringVal("abc123"),
+			}),
+			HostCredentialsToken("abc123"),
+		},
+		"null token": {
+			cty.ObjectVal(map[string]cty.Value{
+				"token": cty.NullVal(cty.String),
+			}),
+			nil,
+		},
+		"unknown token": {
+			cty.ObjectVal(map[string]cty.Value{
+				"token": cty.UnknownVal(cty.String),
+			}),
+			nil,
+		},
+		"basic": {
+			cty.ObjectVal(map[string]cty.Value{
+				"username": cty.StringVal("alfred"),
+				"password": cty.StringVal("hunter2"),
+			}),
+			HostCredentialsBasic{Username: "alfred", Password: "hunter2"},
+		},
+		"headers as map": {
+			cty.ObjectVal(map[string]cty.Value{
+				"headers": cty.MapVal(map[string]cty.Value{
+					"X-Api-Key": cty.StringVal("abc123"),
+				}),
+			}),
+			HostCredentialsHeaders{"X-Api-Key": "abc123"},
+		},
+		"headers as object": {
+			cty.ObjectVal(map[string]cty.Value{
+				"headers": cty.ObjectVal(map[string]cty.Value{
+					"X-Api-Key": cty.StringVal("abc123"),
+				}),
+			}),
+			HostCredentialsHeaders{"X-Api-Key": "abc123"},
+		},
+		"headers with non-string value": {
+			cty.ObjectVal(map[string]cty.Value{
+				"headers": cty.ObjectVal(map[string]cty.Value{
+					"X-Api-Key": cty.True,
+				}),
+			}),
+			nil,
+		},
+		"unknown attributes": {
+			cty.ObjectVal(map[string]cty.Value{
+				"certificate": cty.StringVal("..."),
+			}),
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := HostCredentialsFromObject(test.obj)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result\n%s", diff)
+			}
+		})
+	}
+}
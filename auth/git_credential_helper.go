@@ -0,0 +1,150 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// gitCredentialTokenUsername is the username stored along with credentials
+// that don't have a username of their own, because many git credential
+// helpers will not store credentials without one.
+const gitCredentialTokenUsername = "token"
+
+type gitCredentialHelperSource struct {
+	program helperProgram
+}
+
+// GitCredentialHelperSource returns a CredentialsSource that runs the given
+// program, which must implement the git credential helper protocol, in
+// order to obtain credentials. Such programs are conventionally named with
+// the prefix "git-credential-", and are usually configured for git using the
+// "credential.helper" setting.
+//
+// As with HelperProgramCredentialsSource, the given executable path must be
+// an absolute path, and this function will panic if it is not.
+//
+// Each hostname is passed to the helper as the "host" attribute, in ASCII
+// compatibility form (punycode form) and including any port number, along
+// with the "protocol" attribute "https". The password returned by the helper
+// is used as a bearer token, as for HostCredentialsToken.
+//
+// Credentials stored using this source must have a non-empty token, which
+// is saved as the password. The username of HostCredentialsBasic credentials
+// is saved too, while other credentials are saved with the username "token".
+func GitCredentialHelperSource(executable string, args ...string) CredentialsSource {
+	if !filepath.IsAbs(executable) {
+		panic("GitCredentialHelperSource requires absolute path to executable")
+	}
+
+	return &gitCredentialHelperSource{
+		program: newHelperProgram(executable, args),
+	}
+}
+
+func (s *gitCredentialHelperSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForHostContext(context.Background(), host)
+}
+
+// ForHostContext implements ContextCredentialsSource, terminating the helper
+// program if the given context is cancelled.
+func (s *gitCredentialHelperSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	input, err := gitCredentialInput(host)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.program.run(ctx, input, "get")
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := parseGitCredentialOutput(out)
+	if err != nil {
+		return nil, fmt.Errorf("malformed output from %s: %s", s.program.executable, err)
+	}
+	if attrs["quit"] == "1" || attrs["quit"] == "true" {
+		return nil, fmt.Errorf("%s refused to provide credentials for %s", s.program.executable, host.ForDisplay())
+	}
+	if attrs["password"] == "" {
+		return nil, nil
+	}
+
+	return HostCredentialsToken(attrs["password"]), nil
+}
+
+func (s *gitCredentialHelperSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	if err := checkStoreHost(host); err != nil {
+		return err
+	}
+
+	token := credentials.Token()
+	if token == "" {
+		return fmt.Errorf("can't store credentials without a token in %s", s.program.executable)
+	}
+	username := gitCredentialTokenUsername
+	if basic, ok := credentials.(HostCredentialsBasic); ok && basic.Username != "" {
+		username = basic.Username
+	}
+
+	input, err := gitCredentialInput(host, "username", username, "password", token)
+	if err != nil {
+		return err
+	}
+	_, err = s.program.run(context.Background(), input, "store")
+	return err
+}
+
+func (s *gitCredentialHelperSource) ForgetForHost(host svchost.Hostname) error {
+	input, err := gitCredentialInput(host)
+	if err != nil {
+		return err
+	}
+	_, err = s.program.run(context.Background(), input, "erase")
+	return err
+}
+
+// gitCredentialInput returns the input describing the given host, and then
+// the given additional attribute names and values, in the git credential
+// helper protocol.
+func gitCredentialInput(host svchost.Hostname, extra ...string) ([]byte, error) {
+	attrs := append([]string{"protocol", "https", "host", string(host)}, extra...)
+
+	var buf bytes.Buffer
+	for i := 0; i < len(attrs); i += 2 {
+		name, value := attrs[i], attrs[i+1]
+		// The protocol has no escaping mechanism, so values containing
+		// these characters can't be represented.
+		if strings.ContainsAny(value, "\n\x00") {
+			return nil, fmt.Errorf("can't pass %s containing a newline or NUL character to a git credential helper", name)
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", name, value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// parseGitCredentialOutput parses the given key=value lines from a git
+// credential helper, stopping at the first blank line if there is one.
+func parseGitCredentialOutput(out []byte) (map[string]string, error) {
+	ret := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %q is not of the form key=value", line)
+		}
+		ret[name] = value
+	}
+	return ret, sc.Err()
+}
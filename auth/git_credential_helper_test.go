@@ -0,0 +1,128 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestGitCredentialHelperSource(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program := filepath.Join(wd, "testdata", "git-credential-test")
+	t.Logf("testing with helper at %s", program)
+
+	src := GitCredentialHelperSource(program)
+
+	t.Run("happy path", func(t *testing.T) {
+		creds, err := src.ForHost(svchost.Hostname("example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds, HostCredentialsToken("example-token"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+	t.Run("no credentials", func(t *testing.T) {
+		creds, err := src.ForHost(svchost.Hostname("nothing.example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials; want nil")
+		}
+	})
+	t.Run("lookup error", func(t *testing.T) {
+		_, err := src.ForHost(svchost.Hostname("fail.example.com"))
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if got, want := err.Error(), "failing because you told me to fail"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want message containing %q", got, want)
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		src := HelperProgramTimeout(src, 100*time.Millisecond)
+		_, err := src.ForHost(svchost.Hostname("slow.example.com"))
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if got, want := err.Error(), "did not complete within"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want message containing %q", got, want)
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := CredentialsForHostContext(ctx, src, svchost.Hostname("slow.example.com"))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("wrong error %v; want context.DeadlineExceeded", err)
+		}
+		if got, want := err.Error(), "was interrupted"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want message containing %q", got, want)
+		}
+	})
+	t.Run("store happy path", func(t *testing.T) {
+		err := src.StoreForHost(svchost.Hostname("example.com"), HostCredentialsToken("example-token"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("store error", func(t *testing.T) {
+		err := src.StoreForHost(svchost.Hostname("fail.example.com"), HostCredentialsToken("example-token"))
+		if err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+	t.Run("store invalid token", func(t *testing.T) {
+		err := src.StoreForHost(svchost.Hostname("example.com"), HostCredentialsToken("example\ntoken"))
+		if err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+	t.Run("forget happy path", func(t *testing.T) {
+		err := src.ForgetForHost(svchost.Hostname("example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("forget error", func(t *testing.T) {
+		err := src.ForgetForHost(svchost.Hostname("fail.example.com"))
+		if err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+}
+
+func TestParseGitCredentialOutput(t *testing.T) {
+	got, err := parseGitCredentialOutput([]byte("protocol=https\r\nhost=example.com\npassword=a=b\n\nignored=yes\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"protocol": "https",
+		"host":     "example.com",
+		"password": "a=b",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong result\n%s", diff)
+	}
+
+	if _, err := parseGitCredentialOutput([]byte("nonsense\n")); err == nil {
+		t.Errorf("no error for malformed line")
+	}
+}
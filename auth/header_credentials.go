@@ -0,0 +1,57 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"net/http"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HostCredentialsHeaders is a HostCredentials implementation that represents
+// a set of arbitrary HTTP request headers, such as "X-Api-Key", to be sent
+// to the server verbatim.
+//
+// The map keys are header names and the values are the corresponding header
+// values. Header names are canonicalized when applied to a request.
+type HostCredentialsHeaders map[string]string
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsHeaders does not fully implement these interfaces.
+var _ HostCredentials = HostCredentialsHeaders(nil)
+var _ HostCredentialsWritable = HostCredentialsHeaders(nil)
+
+// PrepareRequest alters the given HTTP request by setting each of the
+// encapsulated headers, replacing any existing values for the same names.
+func (hc HostCredentialsHeaders) PrepareRequest(req *http.Request) {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for name, value := range hc {
+		req.Header.Set(name, value)
+	}
+}
+
+// Token returns an empty string, because a set of headers has no single
+// value that could be considered to be the authentication token.
+func (hc HostCredentialsHeaders) Token() string {
+	return ""
+}
+
+// ToStore returns a credentials object with a single attribute "headers"
+// whose value is a map of header names to header values.
+func (hc HostCredentialsHeaders) ToStore() cty.Value {
+	if len(hc) == 0 {
+		return cty.ObjectVal(map[string]cty.Value{
+			"headers": cty.MapValEmpty(cty.String),
+		})
+	}
+
+	headers := make(map[string]cty.Value, len(hc))
+	for name, value := range hc {
+		headers[name] = cty.StringVal(value)
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"headers": cty.MapVal(headers),
+	})
+}
@@ -0,0 +1,40 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestHostCredentialsHeaders(t *testing.T) {
+	creds := HostCredentialsHeaders{
+		"X-Api-Key": "foo-bar",
+	}
+
+	{
+		req := &http.Request{
+			Header: http.Header{
+				"X-Api-Key": []string{"old-value"},
+			},
+		}
+		creds.PrepareRequest(req)
+		if got, want := req.Header.Values("x-api-key"), []string{"foo-bar"}; len(got) != 1 || got[0] != want[0] {
+			t.Errorf("wrong X-Api-Key header values %q; want %q", got, want)
+		}
+	}
+
+	{
+		got := creds.ToStore()
+		want := cty.ObjectVal(map[string]cty.Value{
+			"headers": cty.MapVal(map[string]cty.Value{
+				"X-Api-Key": cty.StringVal("foo-bar"),
+			}),
+		})
+		if !want.RawEquals(got) {
+			t.Errorf("wrong storable object value\ngot:  %#v\nwant: %#v", got, want)
+		}
+	}
+}
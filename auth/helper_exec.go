@@ -0,0 +1,142 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// helperProgram is a child program that a credentials source runs in order
+// to obtain, store, or forget credentials.
+type helperProgram struct {
+	executable string
+
+	// args are the arguments to pass to the program before the arguments
+	// for a particular operation, starting with the program name itself.
+	args []string
+
+	// timeout is the maximum time that the program may run for a single
+	// operation before it is terminated, or zero for no limit.
+	timeout time.Duration
+}
+
+func newHelperProgram(executable string, args []string) helperProgram {
+	fullArgs := make([]string, len(args)+1)
+	fullArgs[0] = executable
+	copy(fullArgs[1:], args)
+
+	return helperProgram{
+		executable: executable,
+		args:       fullArgs,
+	}
+}
+
+// run runs the program with the receiver's arguments followed by the given
+// extra arguments, writing the given input (if any) to its stdin, and
+// returns what it wrote to stdout.
+//
+// The program is terminated if the given context is cancelled, or if it runs
+// for longer than the receiver's timeout. If the program exits
+// unsuccessfully, the error is a *helperProgramError.
+func (p helperProgram) run(ctx context.Context, stdin []byte, extraArgs ...string) ([]byte, error) {
+	args := make([]string, len(p.args), len(p.args)+len(extraArgs))
+	copy(args, p.args)
+	args = append(args, extraArgs...)
+
+	outBuf := bytes.Buffer{}
+	errBuf := bytes.Buffer{}
+
+	timeoutCtx, cancel := context.WithCancel(ctx)
+	if p.timeout > 0 {
+		timeoutCtx, cancel = context.WithTimeout(ctx, p.timeout)
+	}
+	defer cancel()
+
+	cmd := exec.CommandContext(timeoutCtx, p.executable)
+	cmd.Args = args
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	// If the program starts child processes of its own that inherit its
+	// output pipes, we'd otherwise wait for those to exit too.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%s was interrupted: %w", p.executable, ctx.Err())
+	}
+	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s did not complete within %s", p.executable, p.timeout)
+	}
+	if _, isExitErr := err.(*exec.ExitError); isExitErr {
+		return nil, &helperProgramError{
+			executable: p.executable,
+			stdout:     outBuf.String(),
+			stderr:     errBuf.String(),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %s", p.executable, err)
+	}
+	return outBuf.Bytes(), nil
+}
+
+// HelperProgramTimeout returns a copy of the given credentials source, which
+// must have been created by HelperProgramCredentialsSource,
+// GitCredentialHelperSource, or DockerCredentialHelperSource, that
+// terminates its program, and fails the operation, if the program runs for
+// longer than the given timeout. This function will panic if given any other
+// kind of source.
+//
+// By default these sources wait as long as their programs take, which suits
+// programs that may prompt the user interactively. Use this function, or a
+// context with a deadline, to avoid waiting indefinitely for a program that
+// is stuck.
+func HelperProgramTimeout(source CredentialsSource, timeout time.Duration) CredentialsSource {
+	switch s := source.(type) {
+	case *helperProgramCredentialsSource:
+		ret := *s
+		ret.program.timeout = timeout
+		return &ret
+	case *gitCredentialHelperSource:
+		ret := *s
+		ret.program.timeout = timeout
+		return &ret
+	case *dockerCredentialHelperSource:
+		ret := *s
+		ret.program.timeout = timeout
+		return &ret
+	default:
+		panic(fmt.Sprintf("HelperProgramTimeout requires a helper program credentials source, not %T", source))
+	}
+}
+
+// helperProgramError is the error returned when a helper program exits
+// unsuccessfully.
+type helperProgramError struct {
+	executable string
+
+	// stdout and stderr are the text the program wrote to its stdout and
+	// stderr respectively. Some helper protocols describe errors on
+	// stdout rather than stderr.
+	stdout, stderr string
+}
+
+func (e *helperProgramError) Error() string {
+	errText := e.stderr
+	if strings.TrimSpace(errText) == "" {
+		errText = e.stdout
+	}
+	if strings.TrimSpace(errText) == "" {
+		// Shouldn't happen for a well-behaved helper program
+		return fmt.Sprintf("error in %s, but it produced no error message", e.executable)
+	}
+	return fmt.Sprintf("error in %s: %s", e.executable, errText)
+}
@@ -3,10 +3,9 @@
 package auth
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"path/filepath"
 
 	svchost "github.com/hashicorp/terraform-svchost"
@@ -14,8 +13,7 @@ import (
 )
 
 type helperProgramCredentialsSource struct {
-	executable string
-	args       []string
+	program helperProgram
 }
 
 // HelperProgramCredentialsSource returns a CredentialsSource that runs the
@@ -35,56 +33,36 @@ func HelperProgramCredentialsSource(executable string, args ...string) Credentia
 		panic("NewCredentialsSourceHelperProgram requires absolute path to executable")
 	}
 
-	fullArgs := make([]string, len(args)+1)
-	fullArgs[0] = executable
-	copy(fullArgs[1:], args)
-
 	return &helperProgramCredentialsSource{
-		executable: executable,
-		args:       fullArgs,
+		program: newHelperProgram(executable, args),
 	}
 }
 
 func (s *helperProgramCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
-	args := make([]string, len(s.args), len(s.args)+2)
-	copy(args, s.args)
-	args = append(args, "get", string(host))
-
-	outBuf := bytes.Buffer{}
-	errBuf := bytes.Buffer{}
+	return s.ForHostContext(context.Background(), host)
+}
 
-	cmd := exec.Cmd{
-		Path:   s.executable,
-		Args:   args,
-		Stdin:  nil,
-		Stdout: &outBuf,
-		Stderr: &errBuf,
-	}
-	err := cmd.Run()
-	if _, isExitErr := err.(*exec.ExitError); isExitErr {
-		errText := errBuf.String()
-		if errText == "" {
-			// Shouldn't happen for a well-behaved helper program
-			return nil, fmt.Errorf("error in %s, but it produced no error message", s.executable)
-		}
-		return nil, fmt.Errorf("error in %s: %s", s.executable, errText)
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to run %s: %s", s.executable, err)
+// ForHostContext implements ContextCredentialsSource, terminating the helper
+// program if the given context is cancelled.
+func (s *helperProgramCredentialsSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	out, err := s.program.run(ctx, nil, "get", string(host))
+	if err != nil {
+		return nil, err
 	}
 
 	var m map[string]interface{}
-	err = json.Unmarshal(outBuf.Bytes(), &m)
+	err = json.Unmarshal(out, &m)
 	if err != nil {
-		return nil, fmt.Errorf("malformed output from %s: %s", s.executable, err)
+		return nil, fmt.Errorf("malformed output from %s: %s", s.program.executable, err)
 	}
 
 	return HostCredentialsFromMap(m), nil
 }
 
 func (s *helperProgramCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
-	args := make([]string, len(s.args), len(s.args)+2)
-	copy(args, s.args)
-	args = append(args, "store", string(host))
+	if err := checkStoreHost(host); err != nil {
+		return err
+	}
 
 	toStore := credentials.ToStore()
 	toStoreRaw, err := ctyjson.Marshal(toStore, toStore.Type())
@@ -92,56 +70,11 @@ func (s *helperProgramCredentialsSource) StoreForHost(host svchost.Hostname, cre
 		return fmt.Errorf("can't serialize credentials to store: %s", err)
 	}
 
-	inReader := bytes.NewReader(toStoreRaw)
-	errBuf := bytes.Buffer{}
-
-	cmd := exec.Cmd{
-		Path:   s.executable,
-		Args:   args,
-		Stdin:  inReader,
-		Stderr: &errBuf,
-		Stdout: nil,
-	}
-	err = cmd.Run()
-	if _, isExitErr := err.(*exec.ExitError); isExitErr {
-		errText := errBuf.String()
-		if errText == "" {
-			// Shouldn't happen for a well-behaved helper program
-			return fmt.Errorf("error in %s, but it produced no error message", s.executable)
-		}
-		return fmt.Errorf("error in %s: %s", s.executable, errText)
-	} else if err != nil {
-		return fmt.Errorf("failed to run %s: %s", s.executable, err)
-	}
-
-	return nil
+	_, err = s.program.run(context.Background(), toStoreRaw, "store", string(host))
+	return err
 }
 
 func (s *helperProgramCredentialsSource) ForgetForHost(host svchost.Hostname) error {
-	args := make([]string, len(s.args), len(s.args)+2)
-	copy(args, s.args)
-	args = append(args, "forget", string(host))
-
-	errBuf := bytes.Buffer{}
-
-	cmd := exec.Cmd{
-		Path:   s.executable,
-		Args:   args,
-		Stdin:  nil,
-		Stderr: &errBuf,
-		Stdout: nil,
-	}
-	err := cmd.Run()
-	if _, isExitErr := err.(*exec.ExitError); isExitErr {
-		errText := errBuf.String()
-		if errText == "" {
-			// Shouldn't happen for a well-behaved helper program
-			return fmt.Errorf("error in %s, but it produced no error message", s.executable)
-		}
-		return fmt.Errorf("error in %s: %s", s.executable, errText)
-	} else if err != nil {
-		return fmt.Errorf("failed to run %s: %s", s.executable, err)
-	}
-
-	return nil
+	_, err := s.program.run(context.Background(), nil, "forget", string(host))
+	return err
 }
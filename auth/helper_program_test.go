@@ -70,6 +70,12 @@ func TestHelperProgramCredentialsSource(t *testing.T) {
 			t.Error("completed successfully; want error")
 		}
 	})
+	t.Run("store public suffix wildcard", func(t *testing.T) {
+		err := src.StoreForHost(svchost.Hostname("*.co.uk"), HostCredentialsToken("example-token"))
+		if err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
 	t.Run("forget happy path", func(t *testing.T) {
 		err := src.ForgetForHost(svchost.Hostname("example.com"))
 		if err != nil {
@@ -0,0 +1,101 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// isHostPattern returns true if the given hostname is a wildcard host pattern,
+// as described in the documentation for StaticCredentialsSource.
+func isHostPattern(host svchost.Hostname) bool {
+	return strings.HasPrefix(string(host), "*.")
+}
+
+// hostPatternMatches returns true if the given pattern, which may be either
+// a wildcard host pattern or a literal hostname, matches the given hostname.
+func hostPatternMatches(pattern, host svchost.Hostname) bool {
+	if pattern == host {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(string(pattern), "*.")
+	if !ok {
+		return false
+	}
+	suffixName, suffixPort := splitHostPort(suffix)
+	name, port := splitHostPort(string(host))
+	return port == suffixPort && strings.HasSuffix(name, "."+suffixName)
+}
+
+// hostPatternMoreSpecific returns true if pattern a is more specific than
+// pattern b, assuming that both match the same hostname.
+//
+// A literal hostname is more specific than any wildcard pattern, and a
+// wildcard pattern with a longer suffix is more specific than one with a
+// shorter suffix.
+func hostPatternMoreSpecific(a, b svchost.Hostname) bool {
+	if isHostPattern(a) != isHostPattern(b) {
+		return !isHostPattern(a)
+	}
+	return len(a) > len(b)
+}
+
+// lookupHostPattern returns the value from the given map whose key is the
+// most specific pattern matching the given hostname, as decided by
+// hostPatternMoreSpecific, or false if no key matches.
+func lookupHostPattern[V any](m map[svchost.Hostname]V, host svchost.Hostname) (V, bool) {
+	if v, exists := m[host]; exists {
+		return v, true
+	}
+
+	var best svchost.Hostname
+	found := false
+	for pattern := range m {
+		if !isHostPattern(pattern) || !hostPatternMatches(pattern, host) {
+			continue
+		}
+		if !found || hostPatternMoreSpecific(pattern, best) {
+			best = pattern
+			found = true
+		}
+	}
+	if !found {
+		var zero V
+		return zero, false
+	}
+	return m[best], true
+}
+
+// checkStoreHost returns an error if credentials must not be stored for the
+// given hostname. That is the case for a malformed wildcard host pattern, or
+// for one that would match every hostname under a public suffix, such as
+// "*.com" or "*.co.uk", because such credentials would be sent to hosts
+// belonging to many unrelated organizations.
+func checkStoreHost(host svchost.Hostname) error {
+	if !strings.Contains(string(host), "*") {
+		return nil
+	}
+	suffix, ok := strings.CutPrefix(string(host), "*.")
+	if !ok || suffix == "" || strings.Contains(suffix, "*") {
+		return fmt.Errorf("invalid wildcard host pattern %q: must be \"*.\" followed by a hostname", host)
+	}
+	name, _ := splitHostPort(suffix)
+	if ps, _ := publicsuffix.PublicSuffix(name); ps == name {
+		return fmt.Errorf("can't store credentials for %s: wildcard patterns must not cover an entire public suffix", host)
+	}
+	return nil
+}
+
+// splitHostPort splits the given hostname into its name and port portions,
+// where the port portion is an empty string if there is no port number.
+func splitHostPort(host string) (name, port string) {
+	if i := strings.LastIndexByte(host, ':'); i != -1 {
+		return host[:i], host[i+1:]
+	}
+	return host, ""
+}
@@ -0,0 +1,94 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"testing"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestHostPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern, host svchost.Hostname
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "foo.example.com", false},
+		{"*.example.com", "foo.example.com", true},
+		{"*.example.com", "foo.bar.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "fooexample.com", false},
+		{"*.example.com", "foo.example.com:8443", false},
+		{"*.example.com:8443", "foo.example.com:8443", true},
+		{"*.example.com:8443", "foo.example.com", false},
+		{"*.example.com:8443", "foo.example.com:443", false},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.pattern)+" "+string(test.host), func(t *testing.T) {
+			if got := hostPatternMatches(test.pattern, test.host); got != test.want {
+				t.Errorf("wrong result %t; want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStaticCredentialsSource_wildcard(t *testing.T) {
+	src := StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+		"*.example.com":             {"token": "wildcard"},
+		"*.build.example.com":       {"token": "build"},
+		"special.build.example.com": {"token": "exact"},
+	})
+
+	tests := map[svchost.Hostname]HostCredentials{
+		"example.com":                  nil,
+		"www.example.com":              HostCredentialsToken("wildcard"),
+		"build.example.com":            HostCredentialsToken("wildcard"),
+		"worker1.build.example.com":    HostCredentialsToken("build"),
+		"a.worker1.build.example.com":  HostCredentialsToken("build"),
+		"special.build.example.com":    HostCredentialsToken("exact"),
+		"worker1.build.example.com:22": nil,
+		"example.net":                  nil,
+	}
+
+	for host, want := range tests {
+		t.Run(string(host), func(t *testing.T) {
+			got, err := src.ForHost(host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("wrong credentials %#v; want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckStoreHost(t *testing.T) {
+	tests := map[svchost.Hostname]bool{
+		"example.com":           true,
+		"*.example.com":         true,
+		"*.build.example.co.uk": true,
+		"*.example.com:8443":    true,
+		"*.com":                 false,
+		"*.co.uk":               false,
+		"*.github.io":           false,
+		"*.com:8443":            false,
+		"*":                     false,
+		"foo.*.example.com":     false,
+		"*.*.example.com":       false,
+	}
+
+	for host, wantOK := range tests {
+		t.Run(string(host), func(t *testing.T) {
+			err := checkStoreHost(host)
+			if wantOK && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if !wantOK && err == nil {
+				t.Errorf("no error; want error")
+			}
+		})
+	}
+}
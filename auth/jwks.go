@@ -0,0 +1,368 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// Register the hash functions used by the supported signing algorithms.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// minRSAKeyBits is the minimum size of the RSA keys that JWKS and signJWT
+// accept, since smaller keys can't be relied upon to resist factoring.
+const minRSAKeyBits = 2048
+
+// JWKS is a set of public keys, decoded from a JSON Web Key Set document as
+// defined in IETF RFC 7517 section 5, that can be used to verify the
+// signatures of JSON Web Tokens.
+//
+// Only RSA and elliptic curve public keys are supported. Keys of other
+// types in the document are ignored. RSA keys must be at least 2048 bits.
+type JWKS struct {
+	keys []jwk
+}
+
+// jwk is a single decoded public key from a JWKS document.
+type jwk struct {
+	id string
+
+	// alg is the algorithm the key is restricted to, or an empty string if
+	// the key may be used with any algorithm compatible with its type.
+	alg string
+
+	key crypto.PublicKey
+}
+
+// jwkJSON is the JSON representation of a single key in a JWKS document,
+// including only the properties needed for RSA and elliptic curve keys.
+type jwkJSON struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+
+	// RSA public keys
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// Elliptic curve public keys
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// ParseJWKS decodes the given JSON Web Key Set document.
+//
+// An error is returned if the document is malformed or if any RSA or
+// elliptic curve key within it is invalid.
+func ParseJWKS(src []byte) (*JWKS, error) {
+	var doc struct {
+		Keys []jwkJSON `json:"keys"`
+	}
+	if err := json.Unmarshal(src, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON Web Key Set: %w", err)
+	}
+
+	ret := &JWKS{}
+	for i, raw := range doc.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch raw.KeyType {
+		case "RSA":
+			key, err = parseRSAJWK(raw)
+		case "EC":
+			key, err = parseECJWK(raw)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d in JSON Web Key Set: %w", i, err)
+		}
+
+		ret.keys = append(ret.keys, jwk{
+			id:  raw.KeyID,
+			alg: raw.Algorithm,
+			key: key,
+		})
+	}
+	return ret, nil
+}
+
+func parseRSAJWK(raw jwkJSON) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(raw.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New(`RSA key has invalid modulus "n"`)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(raw.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New(`RSA key has invalid exponent "e"`)
+	}
+
+	exp := 0
+	for _, b := range e {
+		exp = exp<<8 | int(b)
+	}
+	key := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: exp,
+	}
+	if err := checkRSAKeySize(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// checkRSAKeySize returns an error if the given RSA key is smaller than
+// minRSAKeyBits.
+func checkRSAKeySize(key *rsa.PublicKey) error {
+	if bits := key.N.BitLen(); bits < minRSAKeyBits {
+		return fmt.Errorf("RSA key is too small (%d bits; minimum %d)", bits, minRSAKeyBits)
+	}
+	return nil
+}
+
+func parseECJWK(raw jwkJSON) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var ecdhCurve ecdh.Curve
+	switch raw.Curve {
+	case "P-256":
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported elliptic curve %q", raw.Curve)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	x, err := base64.RawURLEncoding.DecodeString(raw.X)
+	if err != nil || len(x) != size {
+		return nil, errors.New(`elliptic curve key has invalid coordinate "x"`)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(raw.Y)
+	if err != nil || len(y) != size {
+		return nil, errors.New(`elliptic curve key has invalid coordinate "y"`)
+	}
+
+	// The ecdh package rejects points that are not on the curve, so we use
+	// it to validate the point before constructing the ECDSA key.
+	point := make([]byte, 0, 1+2*size)
+	point = append(point, 4) // uncompressed form
+	point = append(point, x...)
+	point = append(point, y...)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, errors.New("elliptic curve key is not a valid point on its curve")
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+// VerifyJWT verifies the signature of the given JSON Web Token in compact
+// serialization form using the keys in the receiver, and returns its claims
+// if the signature is valid.
+//
+// If the token header has a "kid" property then only the key with that ID
+// is used. Otherwise, each key compatible with the token's signing algorithm
+// is tried in turn. The supported algorithms are RS256, RS384, RS512, PS256,
+// PS384, PS512, ES256, ES384 and ES512.
+//
+// VerifyJWT does not check the time-based claims, such as "exp" and "nbf".
+func (s *JWKS) VerifyJWT(token string) (*JWTClaims, error) {
+	header, claims, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, ok := jwtAlgorithmHashes[header.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported JSON Web Token signing algorithm %q", header.Algorithm)
+	}
+
+	lastDot := strings.LastIndexByte(token, '.')
+	signingInput := token[:lastDot]
+	sig, err := base64.RawURLEncoding.DecodeString(token[lastDot+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON Web Token signature encoding: %w", err)
+	}
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	for _, k := range s.keys {
+		if header.KeyID != "" && k.id != header.KeyID {
+			continue
+		}
+		if k.alg != "" && k.alg != header.Algorithm {
+			continue
+		}
+		if verifyJWTSignature(header.Algorithm, hash, k.key, digest, sig) {
+			return claims, nil
+		}
+	}
+
+	if header.KeyID != "" {
+		return nil, fmt.Errorf("JSON Web Token signature does not match key %q", header.KeyID)
+	}
+	return nil, errors.New("JSON Web Token signature does not match any key")
+}
+
+// jwtAlgorithmHashes maps each supported JSON Web Signature algorithm to
+// its hash function.
+var jwtAlgorithmHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// jwtAlgorithmCurves maps each supported elliptic curve JSON Web Signature
+// algorithm to the only curve it may be used with.
+var jwtAlgorithmCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+// verifyJWTSignature returns true if sig is a valid signature of digest
+// using the given algorithm and key. It returns false if the key is not
+// of the type that the algorithm requires.
+func verifyJWTSignature(alg string, hash crypto.Hash, key crypto.PublicKey, digest, sig []byte) bool {
+	switch alg[:2] {
+	case "RS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case "PS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve.Params().Name != jwtAlgorithmCurves[alg] {
+			return false
+		}
+		// JWS uses the fixed-size concatenation of R and S rather than the
+		// ASN.1 encoding that the ecdsa package expects.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	default:
+		return false
+	}
+}
+
+// signJWT returns a JSON Web Token in compact serialization form containing
+// the given claims, signed with the given key using the given algorithm,
+// which must be one of those supported by VerifyJWT. If kid is not empty,
+// it is included in the token header as the key ID.
+func signJWT(alg, kid string, key crypto.Signer, claims any) (string, error) {
+	hash, ok := jwtAlgorithmHashes[alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported JSON Web Token signing algorithm %q", alg)
+	}
+
+	var opts crypto.SignerOpts = hash
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		if err := checkRSAKeySize(pub); err != nil {
+			return "", err
+		}
+		if alg[:2] != "RS" && alg[:2] != "PS" {
+			return "", fmt.Errorf("can't use an RSA key with signing algorithm %s", alg)
+		}
+		if alg[:2] == "PS" {
+			opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+		}
+	case *ecdsa.PublicKey:
+		if pub.Curve.Params().Name != jwtAlgorithmCurves[alg] {
+			return "", fmt.Errorf("can't use an elliptic curve key on curve %s with signing algorithm %s", pub.Curve.Params().Name, alg)
+		}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	header := jwtHeader{Algorithm: alg, KeyID: kid}
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(rawHeader) + "." +
+		base64.RawURLEncoding.EncodeToString(rawClaims)
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	sig, err := key.Sign(rand.Reader, h.Sum(nil), opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JSON Web Token: %w", err)
+	}
+
+	if pub, ok := key.Public().(*ecdsa.PublicKey); ok {
+		// crypto.Signer returns ECDSA signatures in ASN.1 form, but JWS
+		// uses the fixed-size concatenation of R and S.
+		var parsed struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+			return "", fmt.Errorf("invalid ECDSA signature: %w", err)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		sig = append(parsed.R.FillBytes(make([]byte, size)), parsed.S.FillBytes(make([]byte, size))...)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// jwtAlgorithmForKey returns the default signing algorithm for the given
+// key: RS256 for RSA keys, or the algorithm matching the curve of an
+// elliptic curve key.
+func jwtAlgorithmForKey(key crypto.Signer) (string, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		if err := checkRSAKeySize(pub); err != nil {
+			return "", err
+		}
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		for alg, curve := range jwtAlgorithmCurves {
+			if curve == pub.Curve.Params().Name {
+				return alg, nil
+			}
+		}
+		return "", fmt.Errorf("unsupported elliptic curve %s", pub.Curve.Params().Name)
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// JWTClaims represents the registered claims of a JSON Web Token, as defined
+// in IETF RFC 7519 section 4.1, that are relevant to deciding how and when
+// to use the token.
+type JWTClaims struct {
+	// Issuer is the "iss" claim, or an empty string if not present.
+	Issuer string
+
+	// Subject is the "sub" claim, or an empty string if not present.
+	Subject string
+
+	// Audience is the "aud" claim, which may be given in the token either
+	// as a single string or as an array of strings.
+	Audience []string
+
+	// ExpiresAt is the "exp" claim, or the zero time if not present.
+	ExpiresAt time.Time
+
+	// NotBefore is the "nbf" claim, or the zero time if not present.
+	NotBefore time.Time
+}
+
+// jwtHeader is the subset of the JOSE header that we need in order to
+// verify a token's signature.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// jwtPayload is the JSON representation of the claims in JWTClaims.
+type jwtPayload struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+}
+
+// ParseJWT decodes the claims from the given JSON Web Token in compact
+// serialization form, without verifying its signature.
+//
+// Because the signature is not verified, the result must be used only for
+// advisory purposes, such as deciding when to refresh the token. Use
+// JWKS.VerifyJWT to verify the signature.
+func ParseJWT(token string) (*JWTClaims, error) {
+	_, claims, err := parseJWT(token)
+	return claims, err
+}
+
+// parseJWT is the main implementation of ParseJWT, which also returns the
+// decoded header so that a caller can verify the signature.
+func parseJWT(token string) (*jwtHeader, *JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, errors.New("not a JSON Web Token: must have three dot-separated parts")
+	}
+
+	headerRaw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON Web Token header encoding: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerRaw, &header); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON Web Token header: %w", err)
+	}
+
+	payloadRaw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON Web Token payload encoding: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(payloadRaw))
+	dec.UseNumber()
+	var payload jwtPayload
+	if err := dec.Decode(&payload); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON Web Token claims: %w", err)
+	}
+
+	claims := &JWTClaims{
+		Issuer:  payload.Issuer,
+		Subject: payload.Subject,
+	}
+	if claims.Audience, err = parseJWTAudience(payload.Audience); err != nil {
+		return nil, nil, err
+	}
+	if claims.ExpiresAt, err = parseJWTTime("exp", payload.ExpiresAt); err != nil {
+		return nil, nil, err
+	}
+	if claims.NotBefore, err = parseJWTTime("nbf", payload.NotBefore); err != nil {
+		return nil, nil, err
+	}
+
+	return &header, claims, nil
+}
+
+func parseJWTAudience(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var multi []string
+	if err := json.Unmarshal(raw, &multi); err != nil {
+		return nil, errors.New(`invalid JSON Web Token "aud" claim: must be a string or an array of strings`)
+	}
+	return multi, nil
+}
+
+func parseJWTTime(name string, n *json.Number) (time.Time, error) {
+	if n == nil {
+		return time.Time{}, nil
+	}
+	f, err := n.Float64()
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return time.Time{}, fmt.Errorf("invalid JSON Web Token %q claim: must be a number of seconds", name)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
+
+// HasAudience returns true if the receiver's audience includes the given
+// hostname.
+//
+// Audience values may be either hostnames or absolute URLs, in which case
+// the hostname portion of the URL is compared. Audience values are compared
+// using the same normalization as svchost.ForComparison.
+func (c *JWTClaims) HasAudience(host svchost.Hostname) bool {
+	for _, aud := range c.Audience {
+		if strings.Contains(aud, "://") {
+			u, err := url.Parse(aud)
+			if err != nil {
+				continue
+			}
+			aud = u.Host
+		}
+		audHost, err := svchost.ForComparison(aud)
+		if err != nil {
+			continue
+		}
+		if audHost == host {
+			return true
+		}
+	}
+	return false
+}
+
+// HostCredentialsJWT is a HostCredentials implementation that represents a
+// bearer token that is a JSON Web Token, along with the claims decoded from
+// it.
+//
+// It behaves in the same way as HostCredentialsToken, except that it
+// implements ExpiringHostCredentials using the token's "exp" claim.
+type HostCredentialsJWT struct {
+	HostCredentialsToken
+	Claims JWTClaims
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsJWT does not fully implement these interfaces.
+var _ ExpiringHostCredentials = HostCredentialsJWT{}
+var _ HostCredentialsWritable = HostCredentialsJWT{}
+
+// ExpiresAt returns the time from the token's "exp" claim, or the zero time
+// if the token has no such claim.
+func (jc HostCredentialsJWT) ExpiresAt() time.Time {
+	return jc.Claims.ExpiresAt
+}
+
+// JWTCredentialsSource creates a new credentials source that wraps another
+// and inspects any HostCredentialsToken credentials it returns, returning
+// HostCredentialsJWT instead for tokens that are JSON Web Tokens.
+//
+// The resulting credentials implement ExpiringHostCredentials, and so
+// CachingCredentialsSource and RefreshingCredentialsSource wrapping the
+// result will take the token's expiry time into account.
+//
+// Tokens inside HostCredentialsExpiring and HostCredentialsOAuth2
+// credentials are inspected too, but those credentials keep their existing
+// expiry time: a HostCredentialsToken inside HostCredentialsExpiring is
+// replaced with HostCredentialsJWT, while HostCredentialsOAuth2 credentials
+// are returned unchanged if their access token passes inspection.
+//
+// A warning is logged if a token has an audience that doesn't include the
+// host it is being used for.
+//
+// If jwks is nil then the token signature is not verified. Otherwise, an
+// error is returned for any JSON Web Token whose signature can't be verified
+// using one of the keys in the given set. Tokens that are not JSON Web Tokens
+// are returned unchanged in either case.
+func JWTCredentialsSource(source CredentialsSource, jwks *JWKS) CredentialsSource {
+	return &jwtCredentialsSource{
+		source: source,
+		jwks:   jwks,
+	}
+}
+
+type jwtCredentialsSource struct {
+	source CredentialsSource
+	jwks   *JWKS
+}
+
+func (s *jwtCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForHostContext(context.Background(), host)
+}
+
+func (s *jwtCredentialsSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	creds, err := CredentialsForHostContext(ctx, s.source, host)
+	if err != nil {
+		return nil, err
+	}
+	return s.inspect(host, creds)
+}
+
+func (s *jwtCredentialsSource) ForScope(scope CredentialsScope) (HostCredentials, error) {
+	creds, err := CredentialsForScope(s.source, scope)
+	if err != nil {
+		return nil, err
+	}
+	return s.inspect(scope.Host, creds)
+}
+
+// inspect implements the JWT handling described in the documentation for
+// JWTCredentialsSource, for credentials that were obtained for the given host.
+func (s *jwtCredentialsSource) inspect(host svchost.Hostname, creds HostCredentials) (HostCredentials, error) {
+	switch c := creds.(type) {
+	case HostCredentialsToken:
+		claims, err := s.checkToken(host, string(c))
+		if err != nil {
+			return nil, err
+		}
+		if claims == nil {
+			return creds, nil
+		}
+		return HostCredentialsJWT{
+			HostCredentialsToken: c,
+			Claims:               *claims,
+		}, nil
+
+	case HostCredentialsExpiring:
+		// The stored expiry time takes precedence over the token's own, so
+		// we keep the wrapper and inspect only the credentials inside it.
+		inner, err := s.inspect(host, c.Credentials)
+		if err != nil {
+			return nil, err
+		}
+		if writable, ok := inner.(HostCredentialsWritable); ok {
+			c.Credentials = writable
+		}
+		return c, nil
+
+	case HostCredentialsOAuth2:
+		// OAuth2 credentials already know when they expire, and must keep
+		// their refresh token, so we only check the access token.
+		if _, err := s.checkToken(host, c.AccessToken); err != nil {
+			return nil, err
+		}
+		return c, nil
+
+	default:
+		return creds, nil
+	}
+}
+
+// checkToken checks the given bearer token, obtained for the given host, as
+// described in the documentation for JWTCredentialsSource. It returns nil
+// claims without an error if the token is not a JSON Web Token.
+func (s *jwtCredentialsSource) checkToken(host svchost.Hostname, token string) (*JWTClaims, error) {
+	claims, err := ParseJWT(token)
+	if err != nil {
+		log.Printf("[TRACE] Token for %s is not a JSON Web Token: %s", host, err)
+		return nil, nil
+	}
+	if s.jwks != nil {
+		if _, err := s.jwks.VerifyJWT(token); err != nil {
+			return nil, fmt.Errorf("invalid token for %s: %w", host.ForDisplay(), err)
+		}
+	}
+
+	if len(claims.Audience) != 0 && !claims.HasAudience(host) {
+		log.Printf("[WARN] Token for %s has audience %q, which does not include that host", host.ForDisplay(), claims.Audience)
+	}
+	return claims, nil
+}
+
+func (s *jwtCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	return s.source.StoreForHost(host, credentials)
+}
+
+func (s *jwtCredentialsSource) ForgetForHost(host svchost.Hostname) error {
+	return s.source.ForgetForHost(host)
+}
+
+func (s *jwtCredentialsSource) InvalidateForHost(host svchost.Hostname) {
+	InvalidateForHost(s.source, host)
+}
@@ -0,0 +1,329 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestParseJWT(t *testing.T) {
+	token := testUnsignedJWT(t, map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"sub": "organization:foo",
+		"aud": "example.com",
+		"exp": 1748779200,
+		"nbf": 1748775600.5,
+	})
+
+	got, err := ParseJWT(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &JWTClaims{
+		Issuer:    "https://issuer.example.com",
+		Subject:   "organization:foo",
+		Audience:  []string{"example.com"},
+		ExpiresAt: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		NotBefore: time.Date(2025, 6, 1, 11, 0, 0, 500000000, time.UTC),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong claims\n%s", diff)
+	}
+
+	for _, invalid := range []string{
+		"not-a-jwt",
+		"a.b.c",
+		testUnsignedJWT(t, map[string]interface{}{"exp": "soon"}),
+		testUnsignedJWT(t, map[string]interface{}{"aud": 12}),
+	} {
+		if _, err := ParseJWT(invalid); err == nil {
+			t.Errorf("no error for invalid token %q", invalid)
+		}
+	}
+}
+
+func TestJWTClaimsHasAudience(t *testing.T) {
+	claims := &JWTClaims{
+		Audience: []string{"https://Example.com/api", "other.example.net:8443"},
+	}
+
+	tests := map[svchost.Hostname]bool{
+		"example.com":            true,
+		"other.example.net:8443": true,
+		"other.example.net":      false,
+		"example.org":            false,
+	}
+	for host, want := range tests {
+		if got := claims.HasAudience(host); got != want {
+			t.Errorf("wrong result for %s: got %t, want %t", host, got, want)
+		}
+	}
+}
+
+func TestJWTCredentialsSource(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksJSON := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa-1","use":"sig","n":%q,"e":"AQAB"},
+		{"kty":"EC","kid":"ec-1","crv":"P-256","x":%q,"y":%q},
+		{"kty":"oct","kid":"ignored","k":"c2VjcmV0"}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+		base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+	)
+	jwks, err := ParseJWKS([]byte(jwksJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiry := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	claims := map[string]interface{}{
+		"aud": "example.com",
+		"exp": expiry.Unix(),
+	}
+	creds := map[svchost.Hostname]map[string]interface{}{
+		"rsa.example.com":    {"token": testSignedJWT(t, "RS256", "rsa-1", rsaKey, claims)},
+		"pss.example.com":    {"token": testSignedJWT(t, "PS256", "", rsaKey, claims)},
+		"ec.example.com":     {"token": testSignedJWT(t, "ES256", "ec-1", ecKey, claims)},
+		"wrong.example.com":  {"token": testSignedJWT(t, "RS256", "rsa-1", otherKey, claims)},
+		"opaque.example.com": {"token": "not-a-jwt"},
+		"expiring.example.com": {
+			"token":      testSignedJWT(t, "RS256", "rsa-1", rsaKey, claims),
+			"expires_at": expiry.Format(time.RFC3339),
+		},
+		"oauth2.example.com": {
+			"token":         testSignedJWT(t, "ES256", "ec-1", ecKey, claims),
+			"refresh_token": "refresh",
+		},
+		"wrong-expiring.example.com": {
+			"token":      testSignedJWT(t, "RS256", "rsa-1", otherKey, claims),
+			"expires_at": expiry.Format(time.RFC3339),
+		},
+		"wrong-oauth2.example.com": {
+			"token":         testSignedJWT(t, "RS256", "rsa-1", otherKey, claims),
+			"refresh_token": "refresh",
+		},
+	}
+
+	t.Run("without verification", func(t *testing.T) {
+		src := JWTCredentialsSource(StaticCredentialsSource(creds), nil)
+		for host := range creds {
+			got, err := src.ForHost(host)
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", host, err)
+			}
+			switch host {
+			case "opaque.example.com":
+				if _, ok := got.(HostCredentialsToken); !ok {
+					t.Errorf("wrong type of credentials for %s: %T", host, got)
+				}
+				continue
+			case "expiring.example.com", "wrong-expiring.example.com":
+				expiring, ok := got.(HostCredentialsExpiring)
+				if !ok {
+					t.Errorf("wrong type of credentials for %s: %T", host, got)
+					continue
+				}
+				if _, ok := expiring.Credentials.(HostCredentialsJWT); !ok {
+					t.Errorf("wrong type of wrapped credentials for %s: %T", host, expiring.Credentials)
+				}
+				continue
+			case "oauth2.example.com", "wrong-oauth2.example.com":
+				if got, ok := got.(HostCredentialsOAuth2); !ok || got.RefreshToken != "refresh" {
+					t.Errorf("wrong credentials for %s: %#v", host, got)
+				}
+				continue
+			}
+			if got, ok := got.(ExpiringHostCredentials); !ok || !got.ExpiresAt().Equal(expiry) {
+				t.Errorf("wrong credentials for %s: %#v", host, got)
+			}
+		}
+	})
+	t.Run("with verification", func(t *testing.T) {
+		src := JWTCredentialsSource(StaticCredentialsSource(creds), jwks)
+		for host := range creds {
+			got, err := src.ForHost(host)
+			if strings.HasPrefix(string(host), "wrong") {
+				if err == nil {
+					t.Errorf("no error for token signed with wrong key")
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", host, err)
+			}
+			if got == nil {
+				t.Errorf("no credentials for %s", host)
+			}
+		}
+	})
+}
+
+func TestSignJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := ParseJWKS([]byte(testJWKSJSON(t, rsaKey, ecKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg     string
+		key     crypto.Signer
+		wantErr bool
+	}{
+		{"RS256", rsaKey, false},
+		{"RS512", rsaKey, false},
+		{"PS384", rsaKey, false},
+		{"ES384", ecKey, false},
+		{"ES256", ecKey, true},
+		{"ES384", rsaKey, true},
+		{"RS256", ecKey, true},
+		{"HS256", rsaKey, true},
+		{"RS256", smallKey, true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %T", test.alg, test.key), func(t *testing.T) {
+			token, err := signJWT(test.alg, "", test.key, map[string]interface{}{"sub": "me"})
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("completed successfully; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := jwks.VerifyJWT(token)
+			if err != nil {
+				t.Fatalf("signature verification failed: %s", err)
+			}
+			if got, want := claims.Subject, "me"; got != want {
+				t.Errorf("wrong subject %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseJWKS_smallRSAKey(t *testing.T) {
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseJWKS([]byte(testJWKSJSON(t, smallKey, ecKey)))
+	if err == nil {
+		t.Fatal("completed successfully; want error")
+	}
+	if got, want := err.Error(), "RSA key is too small"; !strings.Contains(got, want) {
+		t.Errorf("wrong error %q; want message containing %q", got, want)
+	}
+}
+
+// testJWKSJSON returns a JSON Web Key Set document containing the public
+// keys of the given RSA and elliptic curve keys, with the key IDs "rsa" and
+// "ec" respectively.
+func testJWKSJSON(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	params := ecKey.Curve.Params()
+	size := (params.BitSize + 7) / 8
+	return fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa","n":%q,"e":"AQAB"},
+		{"kty":"EC","kid":"ec","crv":%q,"x":%q,"y":%q}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		params.Name,
+		base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, size))),
+		base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, size))),
+	)
+}
+
+func testUnsignedJWT(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func testSignedJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := jwtAlgorithmHashes[alg]
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if alg[:2] == "PS" {
+			sig, err = rsa.SignPSS(rand.Reader, k, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,530 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// DefaultNetrcFilename returns the path of the netrc file that
+// NetrcCredentialsSource should use by default: the file named in the NETRC
+// environment variable if set, or otherwise the file .netrc (_netrc on
+// Windows) in the current user's home directory.
+func DefaultNetrcFilename() (string, error) {
+	if fn := os.Getenv("NETRC"); fn != "" {
+		return fn, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("can't find home directory for netrc file: %w", err)
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name), nil
+}
+
+type netrcCredentialsSource struct {
+	filename string
+}
+
+// NetrcCredentialsSource returns a CredentialsSource that reads and writes
+// credentials in a netrc file with the given filename, such as the one
+// returned by DefaultNetrcFilename. A file that does not exist is treated as
+// containing no credentials.
+//
+// Each "machine" entry provides credentials for the hostname it names, which
+// may include a port number and may be a wildcard host pattern as described
+// for StaticCredentialsSource. The entry's password is used as a bearer
+// token, as for HostCredentialsToken. If no machine entry matches a hostname
+// then the "default" entry is used, if present. The "login" and "account"
+// values are ignored, as are "macdef" macro definitions.
+//
+// StoreForHost updates the password of the first machine entry that names
+// exactly the given hostname, or adds a new entry at the end of the file if
+// there is none. ForgetForHost removes all of the entries naming exactly the
+// given hostname. Neither of them changes the rest of the file, so comments
+// and formatting are preserved. The file is replaced atomically, and is
+// created with permissions that allow only the current user to access it.
+func NetrcCredentialsSource(filename string) CredentialsSource {
+	return &netrcCredentialsSource{
+		filename: filename,
+	}
+}
+
+func (s *netrcCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	_, entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	byHost := map[svchost.Hostname]*netrcEntry{}
+	var defaultEntry *netrcEntry
+	for _, entry := range entries {
+		if entry.isDefault {
+			if defaultEntry == nil {
+				defaultEntry = entry
+			}
+			continue
+		}
+		pattern, ok := entry.hostPattern()
+		if !ok {
+			log.Printf("[TRACE] Ignoring netrc entry for invalid hostname %q", entry.machine.value)
+			continue
+		}
+		// As with other netrc implementations, the first matching entry wins.
+		if _, exists := byHost[pattern]; !exists {
+			byHost[pattern] = entry
+		}
+	}
+
+	entry, ok := lookupHostPattern(byHost, host)
+	if !ok {
+		entry = defaultEntry
+	}
+	if entry == nil || entry.password == nil || entry.password.value == "" {
+		return nil, nil
+	}
+	return HostCredentialsToken(entry.password.value), nil
+}
+
+func (s *netrcCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	if err := checkStoreHost(host); err != nil {
+		return err
+	}
+
+	token := credentials.Token()
+	if token == "" {
+		return fmt.Errorf("can't store credentials without a token in %s", s.filename)
+	}
+	var login string
+	if basic, ok := credentials.(HostCredentialsBasic); ok {
+		login = basic.Username
+	}
+
+	src, entries, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	var edits []netrcEdit
+	if entry := findNetrcEntry(entries, host); entry != nil {
+		edits = append(edits, entry.setValue("password", entry.password, token))
+		if login != "" {
+			edits = append(edits, entry.setValue("login", entry.login, login))
+		}
+	} else {
+		// The default entry must come after all machine entries, so we
+		// insert the new entry before it if there is one, or else at the
+		// end of the file.
+		at := len(src)
+		var buf strings.Builder
+		if entry := findNetrcDefault(entries); entry != nil {
+			at = entry.start
+			lineStart := strings.LastIndexByte(src[:at], '\n') + 1
+			if strings.TrimSpace(src[lineStart:at]) == "" {
+				at = lineStart
+			} else {
+				buf.WriteByte('\n')
+			}
+		} else if len(src) > 0 && !strings.HasSuffix(src, "\n") {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("machine " + string(host))
+		if login != "" {
+			buf.WriteString(" login " + quoteNetrcToken(login))
+		}
+		buf.WriteString(" password " + quoteNetrcToken(token) + "\n")
+		edits = append(edits, netrcEdit{start: at, end: at, text: buf.String()})
+	}
+
+	return s.write(applyNetrcEdits(src, edits))
+}
+
+func (s *netrcCredentialsSource) ForgetForHost(host svchost.Hostname) error {
+	src, entries, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	var edits []netrcEdit
+	for _, entry := range entries {
+		if !entry.isDefault && entry.names(host) {
+			edits = append(edits, entry.removal(src))
+		}
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+
+	return s.write(applyNetrcEdits(src, edits))
+}
+
+// read returns the current contents of the receiver's file, along with the
+// entries parsed from it.
+func (s *netrcCredentialsSource) read() (string, []*netrcEntry, error) {
+	raw, err := os.ReadFile(s.filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil, nil
+	} else if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", s.filename, err)
+	}
+
+	src := string(raw)
+	entries, err := parseNetrc(src)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid netrc file %s: %w", s.filename, err)
+	}
+	return src, entries, nil
+}
+
+// write atomically replaces the receiver's file with the given contents.
+func (s *netrcCredentialsSource) write(src string) error {
+	filename := s.filename
+	if resolved, err := filepath.EvalSymlinks(filename); err == nil {
+		// If the file is a symlink then we'll replace its target instead,
+		// so that the symlink remains in place.
+		filename = resolved
+	}
+
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file to update %s: %w", s.filename, err)
+	}
+	tmpName := f.Name()
+	// CreateTemp uses mode 0600, which is what we want for a file that
+	// contains credentials.
+	_, err = f.WriteString(src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		os.Remove(tmpName) //nolint:errcheck
+		return fmt.Errorf("failed to update %s: %w", s.filename, err)
+	}
+	return nil
+}
+
+// findNetrcEntry returns the first non-default entry that names exactly the
+// given hostname, or nil if there is none.
+func findNetrcEntry(entries []*netrcEntry, host svchost.Hostname) *netrcEntry {
+	for _, entry := range entries {
+		if !entry.isDefault && entry.names(host) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// findNetrcDefault returns the first default entry, or nil if there is none.
+func findNetrcDefault(entries []*netrcEntry) *netrcEntry {
+	for _, entry := range entries {
+		if entry.isDefault {
+			return entry
+		}
+	}
+	return nil
+}
+
+// netrcToken is a single token from a netrc file, along with the byte
+// offsets of its source text, including any quotes.
+type netrcToken struct {
+	value      string
+	start, end int
+}
+
+// netrcEntry is a "machine" or "default" entry from a netrc file.
+type netrcEntry struct {
+	isDefault bool
+
+	// machine is the hostname token, or nil for the default entry.
+	machine *netrcToken
+
+	// login and password are the value tokens of the entry's "login" and
+	// "password" attributes, or nil if the entry doesn't have them.
+	login, password *netrcToken
+
+	// start and end are the byte offsets of the start of the entry's first
+	// token and the end of its last token.
+	start, end int
+}
+
+// hostPattern returns the hostname or wildcard host pattern that the
+// receiver's machine name represents, in the form returned by
+// svchost.ForComparison, or false if the machine name is not valid.
+func (e *netrcEntry) hostPattern() (svchost.Hostname, bool) {
+	name := e.machine.value
+	suffix, isPattern := strings.CutPrefix(name, "*.")
+	if isPattern {
+		name = suffix
+	}
+	host, err := svchost.ForComparison(name)
+	if err != nil {
+		return "", false
+	}
+	if isPattern {
+		return "*." + host, true
+	}
+	return host, true
+}
+
+// names returns true if the receiver's machine name is exactly the given
+// hostname or wildcard host pattern.
+func (e *netrcEntry) names(host svchost.Hostname) bool {
+	pattern, ok := e.hostPattern()
+	return ok && pattern == host
+}
+
+// setValue returns an edit that sets the value of the named attribute of
+// the receiver, whose current value token is the given token or nil if the
+// attribute is not present.
+func (e *netrcEntry) setValue(name string, current *netrcToken, value string) netrcEdit {
+	if current != nil {
+		return netrcEdit{start: current.start, end: current.end, text: quoteNetrcToken(value)}
+	}
+	return netrcEdit{start: e.end, end: e.end, text: " " + name + " " + quoteNetrcToken(value)}
+}
+
+// removal returns an edit that removes the receiver from the given source,
+// along with the remainder of any lines that it occupies entirely.
+func (e *netrcEntry) removal(src string) netrcEdit {
+	start, end := e.start, e.end
+	lineStart := strings.LastIndexByte(src[:start], '\n') + 1
+	if strings.TrimSpace(src[lineStart:start]) == "" {
+		start = lineStart
+	}
+	lineEnd := strings.IndexByte(src[end:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(src) - end
+	} else {
+		lineEnd++ // include the newline
+	}
+	if strings.TrimSpace(src[end:end+lineEnd]) == "" {
+		end += lineEnd
+	}
+	return netrcEdit{start: start, end: end}
+}
+
+// netrcEdit is a replacement of a range of bytes in a netrc file.
+type netrcEdit struct {
+	start, end int
+	text       string
+}
+
+// applyNetrcEdits returns the result of applying the given non-overlapping
+// edits to the given source.
+func applyNetrcEdits(src string, edits []netrcEdit) string {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, edit := range edits {
+		src = src[:edit.start] + edit.text + src[edit.end:]
+	}
+	return src
+}
+
+// quoteNetrcToken returns the given value as a netrc token, quoting it if
+// necessary.
+func quoteNetrcToken(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n\"\\") && value[0] != '#' {
+		return value
+	}
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '"' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// parseNetrc parses the entries in the given netrc file contents.
+func parseNetrc(src string) ([]*netrcEntry, error) {
+	p := netrcParser{src: src}
+	var entries []*netrcEntry
+	var current *netrcEntry
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok == nil {
+			return entries, nil
+		}
+
+		switch tok.value {
+		case "machine", "default":
+			current = &netrcEntry{
+				isDefault: tok.value == "default",
+				start:     tok.start,
+				end:       tok.end,
+			}
+			if !current.isDefault {
+				if current.machine, err = p.value(tok); err != nil {
+					return nil, err
+				}
+				current.end = current.machine.end
+			}
+			entries = append(entries, current)
+
+		case "login", "password", "account":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: %q must be part of a machine or default entry", p.line(tok.start), tok.value)
+			}
+			value, err := p.value(tok)
+			if err != nil {
+				return nil, err
+			}
+			switch tok.value {
+			case "login":
+				current.login = value
+			case "password":
+				current.password = value
+			}
+			current.end = value.end
+
+		case "macdef":
+			if _, err := p.value(tok); err != nil {
+				return nil, err
+			}
+			p.skipMacro()
+			// A macro definition ends any entry that precedes it.
+			current = nil
+
+		default:
+			// Other programs define their own keywords, such as "port", so
+			// we skip unknown keywords along with their values. A keyword
+			// that starts a new entry is never taken as such a value, in
+			// case the unknown keyword doesn't have one.
+			pos := p.pos
+			value, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				return entries, nil
+			}
+			switch value.value {
+			case "machine", "default", "macdef":
+				p.pos = pos
+				continue
+			}
+			if current != nil {
+				current.end = value.end
+			}
+		}
+	}
+}
+
+// netrcParser is a tokenizer for netrc files.
+type netrcParser struct {
+	src string
+	pos int
+}
+
+// next returns the next token, or nil at the end of the input.
+func (p *netrcParser) next() (*netrcToken, error) {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		case c == '#':
+			p.skipLine()
+		default:
+			return p.token()
+		}
+	}
+	return nil, nil
+}
+
+// value returns the token following the given keyword token, which must be
+// present.
+func (p *netrcParser) value(keyword *netrcToken) (*netrcToken, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, fmt.Errorf("line %d: %q must be followed by a value", p.line(keyword.start), keyword.value)
+	}
+	return tok, nil
+}
+
+func (p *netrcParser) token() (*netrcToken, error) {
+	start := p.pos
+	if p.src[p.pos] != '"' {
+		for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		return &netrcToken{value: p.src[start:p.pos], start: start, end: p.pos}, nil
+	}
+
+	p.pos++ // opening quote
+	var buf strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return &netrcToken{value: buf.String(), start: start, end: p.pos}, nil
+		case '\\':
+			if p.pos < len(p.src) {
+				buf.WriteByte(p.src[p.pos])
+				p.pos++
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return nil, fmt.Errorf("line %d: unterminated quoted string", p.line(start))
+}
+
+// skipLine advances to the start of the next line.
+func (p *netrcParser) skipLine() {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i != -1 {
+		p.pos += i + 1
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+// skipMacro skips the body of a macro definition, which begins on the line
+// after the "macdef" keyword and ends at the first empty line.
+func (p *netrcParser) skipMacro() {
+	p.skipLine()
+	for p.pos < len(p.src) {
+		lineStart := p.pos
+		p.skipLine()
+		if strings.TrimRight(p.src[lineStart:p.pos], "\r\n") == "" {
+			return
+		}
+	}
+}
+
+// line returns the one-based line number of the given byte offset.
+func (p *netrcParser) line(pos int) int {
+	return strings.Count(p.src[:pos], "\n") + 1
+}
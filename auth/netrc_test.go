@@ -0,0 +1,189 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+const testNetrc = `# Credentials for CI
+machine example.com
+    login ci
+    password example-token
+
+machine localhost:8443 login me password "with spaces \"and\" quotes"
+
+macdef init
+machine in-macro.example.com password nope
+
+machine *.build.example.com password build-token
+machine EXAMPLE.net password first
+machine example.net password second
+default login anonymous password default-token
+`
+
+func TestNetrcCredentialsSource(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(filename, []byte(testNetrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src := NetrcCredentialsSource(filename)
+
+	tests := map[svchost.Hostname]HostCredentials{
+		"example.com":              HostCredentialsToken("example-token"),
+		"localhost:8443":           HostCredentialsToken(`with spaces "and" quotes`),
+		"worker.build.example.com": HostCredentialsToken("build-token"),
+		"example.net":              HostCredentialsToken("first"),
+		"in-macro.example.com":     HostCredentialsToken("default-token"),
+		"localhost":                HostCredentialsToken("default-token"),
+		"unconfigured.example.org": HostCredentialsToken("default-token"),
+	}
+	for host, want := range tests {
+		t.Run(string(host), func(t *testing.T) {
+			got, err := src.ForHost(host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("wrong credentials %#v; want %#v", got, want)
+			}
+		})
+	}
+
+	t.Run("file does not exist", func(t *testing.T) {
+		src := NetrcCredentialsSource(filepath.Join(t.TempDir(), "nonexist"))
+		got, err := src.ForHost("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != nil {
+			t.Errorf("got credentials; want nil")
+		}
+	})
+	t.Run("unknown keywords", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), ".netrc")
+		src := "machine example.com port 8443 login me password secret\nmachine example.net password other protocol\n"
+		if err := os.WriteFile(filename, []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+		source := NetrcCredentialsSource(filename)
+		got, err := source.ForHost("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := HostCredentialsToken("secret"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+		got, err = source.ForHost("example.net")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := HostCredentialsToken("other"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+
+		// Removing an entry also removes its unknown attributes.
+		if err := source.ForgetForHost("example.com"); err != nil {
+			t.Fatal(err)
+		}
+		result, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(result), "machine example.net password other protocol\n"; got != want {
+			t.Errorf("wrong file contents %q; want %q", got, want)
+		}
+	})
+	t.Run("malformed file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), ".netrc")
+		if err := os.WriteFile(filename, []byte("machine example.com password \"unterminated\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := NetrcCredentialsSource(filename).ForHost("example.com")
+		if err == nil {
+			t.Errorf("no error for malformed file")
+		}
+	})
+}
+
+func TestNetrcCredentialsSource_store(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(filename, []byte(testNetrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := NetrcCredentialsSource(filename)
+
+	if err := src.StoreForHost("example.com", HostCredentialsToken("new-token")); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.StoreForHost("localhost:8443", HostCredentialsBasic{Username: "you", Password: "new password"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.StoreForHost("new.example.org", HostCredentialsToken("added")); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.ForgetForHost("example.net"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.ForgetForHost("unconfigured.example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Credentials for CI
+machine example.com
+    login ci
+    password new-token
+
+machine localhost:8443 login you password "new password"
+
+macdef init
+machine in-macro.example.com password nope
+
+machine *.build.example.com password build-token
+machine new.example.org password added
+default login anonymous password default-token
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("wrong file contents\n%s", diff)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.Mode().Perm(), os.FileMode(0600); got != want && runtime.GOOS != "windows" {
+		t.Errorf("wrong file mode %s; want %s", got, want)
+	}
+
+	t.Run("public suffix wildcard", func(t *testing.T) {
+		err := src.StoreForHost("*.com", HostCredentialsToken("nope"))
+		if err == nil {
+			t.Errorf("completed successfully; want error")
+		}
+	})
+	t.Run("new file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), ".netrc")
+		src := NetrcCredentialsSource(filename)
+		if err := src.StoreForHost("example.com", HostCredentialsToken("abc123")); err != nil {
+			t.Fatal(err)
+		}
+		got, err := src.ForHost("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := HostCredentialsToken("abc123"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/oauth2"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// refreshTokenAttr is the name of the attribute used to persist the refresh
+// token of HostCredentialsOAuth2.
+const refreshTokenAttr = "refresh_token"
+
+// HostCredentialsOAuth2 is a HostCredentials implementation that represents
+// an OAuth2 access token along with the refresh token and expiry time that
+// an authorization server issued with it.
+//
+// The access token is sent to the server in the same way as for
+// HostCredentialsToken. Use OAuth2RefreshFunc with RefreshingCredentialsSource
+// to obtain a new access token using the refresh token before the access
+// token expires.
+type HostCredentialsOAuth2 struct {
+	AccessToken  string
+	RefreshToken string
+
+	// Expiry is the time when the access token expires, or the zero time
+	// if the authorization server didn't specify an expiry time.
+	Expiry time.Time
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsOAuth2 does not fully implement these interfaces.
+var _ ExpiringHostCredentials = HostCredentialsOAuth2{}
+var _ HostCredentialsWritable = HostCredentialsOAuth2{}
+
+// HostCredentialsFromOAuth2Token returns a HostCredentialsOAuth2 representing
+// the given token returned by the oauth2 library.
+func HostCredentialsFromOAuth2Token(tok *oauth2.Token) HostCredentialsOAuth2 {
+	return HostCredentialsOAuth2{
+		AccessToken:  tok.AccessToken,
+		RefreshToken: tok.RefreshToken,
+		Expiry:       tok.Expiry,
+	}
+}
+
+// PrepareRequest alters the given HTTP request by setting its Authorization
+// header to the string "Bearer " followed by the access token.
+func (oc HostCredentialsOAuth2) PrepareRequest(req *http.Request) {
+	HostCredentialsToken(oc.AccessToken).PrepareRequest(req)
+}
+
+// Token returns the access token.
+func (oc HostCredentialsOAuth2) Token() string {
+	return oc.AccessToken
+}
+
+// ExpiresAt returns the time when the access token expires.
+func (oc HostCredentialsOAuth2) ExpiresAt() time.Time {
+	return oc.Expiry
+}
+
+// OAuth2Token returns the receiver as a token for use with the oauth2 library.
+func (oc HostCredentialsOAuth2) OAuth2Token() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  oc.AccessToken,
+		RefreshToken: oc.RefreshToken,
+		Expiry:       oc.Expiry,
+	}
+}
+
+// ToStore returns a credentials object with the attribute "token" whose value
+// is the access token, the attribute "refresh_token", and the attribute
+// "expires_at" if the expiry time is known.
+//
+// Because the access token is stored as "token", software that is unaware of
+// refresh tokens will still interpret the result as HostCredentialsToken.
+func (oc HostCredentialsOAuth2) ToStore() cty.Value {
+	attrs := map[string]cty.Value{
+		"token":          cty.StringVal(oc.AccessToken),
+		refreshTokenAttr: cty.StringVal(oc.RefreshToken),
+	}
+	if !oc.Expiry.IsZero() {
+		attrs[expiresAtAttr] = cty.StringVal(oc.Expiry.UTC().Format(time.RFC3339))
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// oauth2Decoder is the HostCredentialsDecoder for HostCredentialsOAuth2.
+type oauth2Decoder struct{}
+
+func (oauth2Decoder) FromMap(m map[string]interface{}) HostCredentials {
+	accessToken, ok := m["token"].(string)
+	if !ok {
+		return nil
+	}
+	refreshToken, ok := m[refreshTokenAttr].(string)
+	if !ok {
+		return nil
+	}
+	ret := HostCredentialsOAuth2{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+	if raw, ok := m[expiresAtAttr].(string); ok {
+		ret.Expiry, _ = time.Parse(time.RFC3339, raw)
+	}
+	return ret
+}
+
+func (oauth2Decoder) FromObject(obj cty.Value) HostCredentials {
+	accessToken, ok := stringAttr(obj, "token")
+	if !ok {
+		return nil
+	}
+	refreshToken, ok := stringAttr(obj, refreshTokenAttr)
+	if !ok {
+		return nil
+	}
+	ret := HostCredentialsOAuth2{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+	if raw, ok := stringAttr(obj, expiresAtAttr); ok {
+		ret.Expiry, _ = time.Parse(time.RFC3339, raw)
+	}
+	return ret
+}
+
+// OAuth2RefreshFunc returns a RefreshFunc, for use with
+// RefreshingCredentialsSource, that uses the refresh token of
+// HostCredentialsOAuth2 credentials to obtain a new access token from the
+// token endpoint of the OAuth2 configuration returned by configForHost.
+//
+// The given HTTP client is used to make requests to the token endpoint. If
+// it is nil, the oauth2 library's default client is used instead. The
+// context given to the resulting function is passed on to configForHost and
+// used for the request to the token endpoint.
+//
+// The resulting function does nothing for any other kind of credentials, or
+// for HostCredentialsOAuth2 credentials that have no refresh token.
+func OAuth2RefreshFunc(configForHost func(ctx context.Context, host svchost.Hostname) (*oauth2.Config, error), client *http.Client) RefreshFunc {
+	return func(ctx context.Context, host svchost.Hostname, current HostCredentials) (HostCredentialsWritable, error) {
+		oc, ok := current.(HostCredentialsOAuth2)
+		if !ok || oc.RefreshToken == "" {
+			return nil, nil
+		}
+
+		config, err := configForHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		if client != nil {
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+		}
+
+		// A token without an access token is always considered to be
+		// invalid, which forces the token source to use the refresh token.
+		tok, err := config.TokenSource(ctx, &oauth2.Token{
+			RefreshToken: oc.RefreshToken,
+		}).Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh OAuth2 token: %w", err)
+		}
+
+		return HostCredentialsFromOAuth2Token(tok), nil
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/oauth2"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestHostCredentialsOAuth2(t *testing.T) {
+	creds := HostCredentialsOAuth2{
+		AccessToken:  "foo-bar",
+		RefreshToken: "baz",
+		Expiry:       time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	{
+		req := &http.Request{}
+		creds.PrepareRequest(req)
+		authStr := req.Header.Get("authorization")
+		if got, want := authStr, "Bearer foo-bar"; got != want {
+			t.Errorf("wrong Authorization header value %q; want %q", got, want)
+		}
+	}
+
+	{
+		got := creds.ToStore()
+		want := cty.ObjectVal(map[string]cty.Value{
+			"token":         cty.StringVal("foo-bar"),
+			"refresh_token": cty.StringVal("baz"),
+			"expires_at":    cty.StringVal("2025-06-01T12:00:00Z"),
+		})
+		if !want.RawEquals(got) {
+			t.Errorf("wrong storable object value\ngot:  %#v\nwant: %#v", got, want)
+		}
+
+		if diff := cmp.Diff(HostCredentials(creds), HostCredentialsFromObject(got)); diff != "" {
+			t.Errorf("wrong result from object\n%s", diff)
+		}
+	}
+
+	{
+		got := HostCredentialsFromMap(map[string]interface{}{
+			"token":         "foo-bar",
+			"refresh_token": "baz",
+			"expires_at":    "2025-06-01T12:00:00Z",
+		})
+		if diff := cmp.Diff(HostCredentials(creds), got); diff != "" {
+			t.Errorf("wrong result from map\n%s", diff)
+		}
+	}
+}
+
+func TestOAuth2RefreshFunc(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	now := time.Now()
+
+	var gotForm map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("invalid token request: %s", err)
+		}
+		gotForm = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access","token_type":"bearer","refresh_token":"new-refresh","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	mem := newTestMemorySource()
+	mem.creds[host] = HostCredentialsOAuth2{
+		AccessToken:  "old-access",
+		RefreshToken: "old-refresh",
+		Expiry:       now.Add(30 * time.Second),
+	}
+
+	refresh := OAuth2RefreshFunc(func(ctx context.Context, h svchost.Hostname) (*oauth2.Config, error) {
+		return &oauth2.Config{
+			ClientID: "terraform-cli",
+			Endpoint: oauth2.Endpoint{
+				TokenURL:  server.URL + "/token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+		}, nil
+	}, server.Client())
+	src := RefreshingCredentialsSource(mem, time.Minute, refresh)
+
+	creds, err := src.ForHost(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantForm := map[string][]string{
+		"client_id":     {"terraform-cli"},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {"old-refresh"},
+	}
+	if diff := cmp.Diff(wantForm, gotForm); diff != "" {
+		t.Errorf("wrong token request\n%s", diff)
+	}
+
+	oc, ok := creds.(HostCredentialsOAuth2)
+	if !ok {
+		t.Fatalf("wrong type of credentials %T", creds)
+	}
+	if got, want := oc.AccessToken, "new-access"; got != want {
+		t.Errorf("wrong access token %q; want %q", got, want)
+	}
+	if got, want := oc.RefreshToken, "new-refresh"; got != want {
+		t.Errorf("wrong refresh token %q; want %q", got, want)
+	}
+	if !oc.Expiry.After(now.Add(time.Hour - time.Minute)) {
+		t.Errorf("wrong expiry %s", oc.Expiry)
+	}
+	if diff := cmp.Diff(HostCredentialsWritable(oc), mem.creds[host]); diff != "" {
+		t.Errorf("refreshed credentials were not stored\n%s", diff)
+	}
+
+	// Other kinds of credentials are not refreshed.
+	refreshed, err := refresh(context.Background(), host, HostCredentialsToken("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed != nil {
+		t.Errorf("refreshed token credentials; want nil")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// RefreshFunc is the signature of a function that can obtain replacement
+// credentials for the given host when the current credentials are expired
+// or about to expire.
+//
+// A RefreshFunc should return nil, nil if it doesn't know how to refresh the
+// given credentials, in which case the caller will use the current
+// credentials as-is. It should stop and return an error if the given context
+// is cancelled.
+type RefreshFunc func(ctx context.Context, host svchost.Hostname, current HostCredentials) (HostCredentialsWritable, error)
+
+// RefreshingCredentialsSource creates a new credentials source that wraps
+// another, caches its results in memory on a per-hostname basis, and obtains
+// new credentials shortly before cached credentials expire.
+//
+// Only credentials implementing ExpiringHostCredentials with a non-zero
+// expiry time are subject to refreshing. Such credentials are considered to
+// need refreshing once the current time is within the given skew of their
+// expiry time.
+//
+// When cached credentials need refreshing, the wrapped source is first asked
+// for credentials again, in case some other process has already obtained
+// fresh credentials. If the result also needs refreshing and refresh is not
+// nil, the refresh function is called and any new credentials it returns are
+// saved using the wrapped source's StoreForHost method before being returned.
+func RefreshingCredentialsSource(source CredentialsSource, skew time.Duration, refresh RefreshFunc) CredentialsSource {
+	return &refreshingCredentialsSource{
+		source:  source,
+		skew:    skew,
+		refresh: refresh,
+		cache:   map[svchost.Hostname]HostCredentials{},
+		hostMu:  map[svchost.Hostname]chan struct{}{},
+		now:     time.Now,
+	}
+}
+
+type refreshingCredentialsSource struct {
+	source  CredentialsSource
+	skew    time.Duration
+	refresh RefreshFunc
+
+	// now is overridden during tests to simulate the passage of time.
+	now func() time.Time
+
+	// must lock "mu" while interacting with cache or hostMu
+	cache map[svchost.Hostname]HostCredentials
+	mu    sync.Mutex
+
+	// hostMu has a channel for each host that acts as a lock, held while
+	// obtaining new credentials for that host. A channel, rather than a
+	// mutex, allows callers to stop waiting when their context is
+	// cancelled.
+	hostMu map[svchost.Hostname]chan struct{}
+}
+
+// ForHost returns cached credentials for the given host if they are not
+// about to expire, or otherwise obtains new credentials as described in the
+// documentation for RefreshingCredentialsSource.
+//
+// Unlike CachingCredentialsSource, this holds a lock for the host while
+// obtaining new credentials so that concurrent callers for the same host will
+// not try to refresh the same credentials more than once. Callers for other
+// hosts are not affected.
+func (s *refreshingCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForHostContext(context.Background(), host)
+}
+
+// ForHostContext is like ForHost, but uses CredentialsForHostContext to
+// obtain credentials from the wrapped source and passes the given context to
+// the refresh function. It stops waiting for another caller that is
+// obtaining credentials for the same host if the context is cancelled.
+func (s *refreshingCredentialsSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	if creds, ok := s.cached(host); ok {
+		return creds, nil
+	}
+
+	unlock, err := s.lockHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another caller may have obtained new credentials while we waited.
+	if creds, ok := s.cached(host); ok {
+		return creds, nil
+	}
+
+	creds, err := CredentialsForHostContext(ctx, s.source, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.needsRefresh(creds) && s.refresh != nil {
+		refreshed, err := s.refresh(ctx, host, creds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh credentials for %s: %w", host.ForDisplay(), err)
+		}
+		if refreshed != nil {
+			if err := s.source.StoreForHost(host, refreshed); err != nil {
+				// The refreshed credentials are still usable for this process
+				// even if we can't save them for the next one.
+				log.Printf("[WARN] Failed to store refreshed credentials for %s: %s", host, err)
+			}
+			creds = refreshed
+		}
+	}
+
+	s.mu.Lock()
+	s.cache[host] = creds
+	s.mu.Unlock()
+	return creds, nil
+}
+
+// cached returns the cached credentials for the given host, if there are
+// any that don't need refreshing.
+func (s *refreshingCredentialsSource) cached(host svchost.Hostname) (HostCredentials, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, ok := s.cache[host]
+	if !ok || s.needsRefresh(creds) {
+		return nil, false
+	}
+	return creds, true
+}
+
+// lockHost waits until no other caller is obtaining credentials for the
+// given host, or until the given context is cancelled, and returns a
+// function that releases the lock.
+func (s *refreshingCredentialsSource) lockHost(ctx context.Context, host svchost.Hostname) (func(), error) {
+	s.mu.Lock()
+	lock, ok := s.hostMu[host]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		s.hostMu[host] = lock
+	}
+	s.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ForScope passes the given scope on to the wrapped source using
+// CredentialsForScope. Credentials for scopes narrower than a whole host are
+// neither cached nor refreshed.
+func (s *refreshingCredentialsSource) ForScope(scope CredentialsScope) (HostCredentials, error) {
+	if scope.ServiceID == "" && scope.Path == "" {
+		return s.ForHost(scope.Host)
+	}
+	return CredentialsForScope(s.source, scope)
+}
+
+func (s *refreshingCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	s.mu.Lock()
+	delete(s.cache, host)
+	s.mu.Unlock()
+	return s.source.StoreForHost(host, credentials)
+}
+
+func (s *refreshingCredentialsSource) ForgetForHost(host svchost.Hostname) error {
+	s.mu.Lock()
+	delete(s.cache, host)
+	s.mu.Unlock()
+	return s.source.ForgetForHost(host)
+}
+
+// InvalidateForHost discards the cache entry for the given host, and also
+// invalidates the wrapped source.
+func (s *refreshingCredentialsSource) InvalidateForHost(host svchost.Hostname) {
+	s.mu.Lock()
+	delete(s.cache, host)
+	s.mu.Unlock()
+	InvalidateForHost(s.source, host)
+}
+
+// needsRefresh returns true if the given credentials have a known expiry
+// time that is within the receiver's skew of the current time.
+func (s *refreshingCredentialsSource) needsRefresh(creds HostCredentials) bool {
+	expiry, ok := credentialsExpiry(creds)
+	if !ok {
+		return false
+	}
+	return !s.now().Add(s.skew).Before(expiry)
+}
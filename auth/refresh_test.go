@@ -0,0 +1,242 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// testMemorySource is a writable in-memory CredentialsSource for testing
+// wrappers, which counts the number of lookups made for each host.
+type testMemorySource struct {
+	creds   map[svchost.Hostname]HostCredentialsWritable
+	lookups map[svchost.Hostname]int
+}
+
+func newTestMemorySource() *testMemorySource {
+	return &testMemorySource{
+		creds:   map[svchost.Hostname]HostCredentialsWritable{},
+		lookups: map[svchost.Hostname]int{},
+	}
+}
+
+func (s *testMemorySource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	s.lookups[host]++
+	if creds, ok := s.creds[host]; ok {
+		return creds, nil
+	}
+	return nil, nil
+}
+
+func (s *testMemorySource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	s.creds[host] = credentials
+	return nil
+}
+
+func (s *testMemorySource) ForgetForHost(host svchost.Hostname) error {
+	delete(s.creds, host)
+	return nil
+}
+
+func TestRefreshingCredentialsSource(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	mem := newTestMemorySource()
+	mem.creds[host] = HostCredentialsExpiring{
+		Credentials: HostCredentialsToken("first"),
+		Expiry:      start.Add(10 * time.Minute),
+	}
+
+	refreshes := 0
+	src := RefreshingCredentialsSource(mem, time.Minute, func(ctx context.Context, h svchost.Hostname, current HostCredentials) (HostCredentialsWritable, error) {
+		if h != host {
+			t.Errorf("refresh for wrong host %s", h)
+		}
+		refreshes++
+		if current.Token() == "fail" {
+			return nil, errors.New("refresh failed")
+		}
+		return HostCredentialsExpiring{
+			Credentials: HostCredentialsToken("refreshed"),
+			Expiry:      now.Add(10 * time.Minute),
+		}, nil
+	})
+	src.(*refreshingCredentialsSource).now = func() time.Time { return now }
+
+	t.Run("fresh credentials are cached", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			creds, err := src.ForHost(host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := creds.Token(), "first"; got != want {
+				t.Errorf("wrong token %q; want %q", got, want)
+			}
+		}
+		if got, want := mem.lookups[host], 1; got != want {
+			t.Errorf("wrong number of lookups %d; want %d", got, want)
+		}
+		if refreshes != 0 {
+			t.Errorf("refreshed %d times; want 0", refreshes)
+		}
+	})
+	t.Run("credentials within skew are refreshed", func(t *testing.T) {
+		now = start.Add(9*time.Minute + 30*time.Second)
+		creds, err := src.ForHost(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds.Token(), "refreshed"; got != want {
+			t.Errorf("wrong token %q; want %q", got, want)
+		}
+		if got, want := mem.creds[host].Token(), "refreshed"; got != want {
+			t.Errorf("wrong stored token %q; want %q", got, want)
+		}
+		if got, want := refreshes, 1; got != want {
+			t.Errorf("refreshed %d times; want %d", got, want)
+		}
+	})
+	t.Run("credentials refreshed elsewhere are re-fetched", func(t *testing.T) {
+		now = now.Add(20 * time.Minute)
+		mem.creds[host] = HostCredentialsExpiring{
+			Credentials: HostCredentialsToken("from elsewhere"),
+			Expiry:      now.Add(10 * time.Minute),
+		}
+		creds, err := src.ForHost(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds.Token(), "from elsewhere"; got != want {
+			t.Errorf("wrong token %q; want %q", got, want)
+		}
+		if got, want := refreshes, 1; got != want {
+			t.Errorf("refreshed %d times; want %d", got, want)
+		}
+	})
+	t.Run("refresh error", func(t *testing.T) {
+		now = now.Add(20 * time.Minute)
+		mem.creds[host] = HostCredentialsExpiring{
+			Credentials: HostCredentialsToken("fail"),
+			Expiry:      now,
+		}
+		_, err := src.ForHost(host)
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+	})
+	t.Run("credentials without expiry", func(t *testing.T) {
+		if err := src.StoreForHost(host, HostCredentialsToken("forever")); err != nil {
+			t.Fatal(err)
+		}
+		before := refreshes
+		now = now.Add(1000 * time.Hour)
+		creds, err := src.ForHost(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds.Token(), "forever"; got != want {
+			t.Errorf("wrong token %q; want %q", got, want)
+		}
+		if refreshes != before {
+			t.Errorf("refreshed credentials that have no expiry")
+		}
+	})
+}
+
+func TestRefreshingCredentialsSource_concurrent(t *testing.T) {
+	slow := &testBlockingSource{
+		CredentialsSource: StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+			"slow.example.com": {"token": "slow"},
+			"fast.example.com": {"token": "fast"},
+		}),
+		host:    "slow.example.com",
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	src := RefreshingCredentialsSource(slow, time.Minute, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = src.ForHost("slow.example.com")
+	}()
+	defer func() {
+		close(slow.release)
+		<-done
+	}()
+	<-slow.started
+
+	t.Run("other host", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		creds, err := CredentialsForHostContext(ctx, src, "fast.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds.Token(), "fast"; got != want {
+			t.Errorf("wrong token %q; want %q", got, want)
+		}
+	})
+	t.Run("same host", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := CredentialsForHostContext(ctx, src, "slow.example.com")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wrong error %v; want context.DeadlineExceeded", err)
+		}
+	})
+}
+
+// testBlockingSource wraps another CredentialsSource, blocking lookups for
+// one host until release is closed.
+type testBlockingSource struct {
+	CredentialsSource
+	host             svchost.Hostname
+	started, release chan struct{}
+}
+
+func (s *testBlockingSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	if host == s.host {
+		close(s.started)
+		<-s.release
+	}
+	return s.CredentialsSource.ForHost(host)
+}
+
+func TestCachingCredentialsSource_expiry(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mem := newTestMemorySource()
+	mem.creds[host] = HostCredentialsExpiring{
+		Credentials: HostCredentialsToken("abc123"),
+		Expiry:      now.Add(time.Minute),
+	}
+
+	src := CachingCredentialsSource(mem)
+	src.(*cachingCredentialsSource).now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := src.ForHost(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := mem.lookups[host], 1; got != want {
+		t.Errorf("wrong number of lookups before expiry %d; want %d", got, want)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := src.ForHost(host); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mem.lookups[host], 2; got != want {
+		t.Errorf("wrong number of lookups after expiry %d; want %d", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HostCredentialsDecoder is implemented by objects that can decode one
+// particular kind of credentials from a credentials definition, for use with
+// RegisterCredentialsKey and RegisterCredentialsType.
+type HostCredentialsDecoder interface {
+	// FromMap decodes credentials from a map of key-value pairs, such as
+	// one decoded from a JSON object, returning nil if the map does not
+	// contain a valid definition of this decoder's credentials kind.
+	//
+	// Implementations should ignore map keys they are unfamiliar with.
+	FromMap(m map[string]interface{}) HostCredentials
+
+	// FromObject decodes credentials from a cty.Value of an object type,
+	// returning nil if the object does not contain a valid definition of
+	// this decoder's credentials kind.
+	//
+	// Implementations should ignore attributes they are unfamiliar with,
+	// and must tolerate null and unknown attribute values.
+	FromObject(obj cty.Value) HostCredentials
+}
+
+// credentialsTypeAttr is the name of the optional attribute that explicitly
+// selects the decoder for a credentials definition.
+const credentialsTypeAttr = "type"
+
+// credentialsRegistry tracks the decoders registered with
+// RegisterCredentialsKey and RegisterCredentialsType.
+type credentialsRegistry struct {
+	// keys is in registration order, because that order determines the
+	// precedence of the discriminator keys.
+	keys     []registeredCredentialsKey
+	keyNames map[string]struct{}
+	types    map[string]HostCredentialsDecoder
+	mu       sync.RWMutex
+}
+
+type registeredCredentialsKey struct {
+	key     string
+	decoder HostCredentialsDecoder
+}
+
+var registry = &credentialsRegistry{
+	keyNames: map[string]struct{}{},
+	types:    map[string]HostCredentialsDecoder{},
+}
+
+func init() {
+	// The built-in credentials kinds are registered first so that they
+	// always take precedence over kinds registered by other packages.
+	// OAuth2 credentials also have a "token" key, so they must be
+	// registered before plain tokens.
+	RegisterCredentialsKey("refresh_token", oauth2Decoder{})
+	RegisterCredentialsKey("token", tokenDecoder{})
+	RegisterCredentialsKey("username", basicDecoder{})
+	RegisterCredentialsKey("headers", headersDecoder{})
+
+	RegisterCredentialsType("token", tokenDecoder{})
+	RegisterCredentialsType("basic", basicDecoder{})
+	RegisterCredentialsType("headers", headersDecoder{})
+	RegisterCredentialsType("oauth2", oauth2Decoder{})
+}
+
+// RegisterCredentialsKey registers a decoder to be consulted by
+// HostCredentialsFromMap and HostCredentialsFromObject whenever a credentials
+// definition has no "type" attribute naming a registered type but does
+// include the given key.
+//
+// Discriminator keys are consulted in the order they were registered, and the
+// first decoder that returns non-nil credentials wins. The built-in keys
+// "refresh_token", "token", "username" and "headers" are always registered
+// first.
+//
+// This function is intended to be called from package init functions. It
+// panics if the given key is already registered, or if it is "type".
+func RegisterCredentialsKey(key string, decoder HostCredentialsDecoder) {
+	if key == credentialsTypeAttr {
+		panic(fmt.Sprintf("credentials discriminator key cannot be %q", credentialsTypeAttr))
+	}
+	if decoder == nil {
+		panic("RegisterCredentialsKey requires a non-nil decoder")
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, exists := registry.keyNames[key]; exists {
+		panic(fmt.Sprintf("credentials discriminator key %q is already registered", key))
+	}
+	registry.keyNames[key] = struct{}{}
+	registry.keys = append(registry.keys, registeredCredentialsKey{
+		key:     key,
+		decoder: decoder,
+	})
+}
+
+// RegisterCredentialsType registers a decoder to be consulted by
+// HostCredentialsFromMap and HostCredentialsFromObject whenever a credentials
+// definition has a string "type" attribute with the given value.
+//
+// The built-in types are "token", "basic", "headers" and "oauth2".
+//
+// This function is intended to be called from package init functions. It
+// panics if the given type name is already registered.
+func RegisterCredentialsType(typeName string, decoder HostCredentialsDecoder) {
+	if decoder == nil {
+		panic("RegisterCredentialsType requires a non-nil decoder")
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, exists := registry.types[typeName]; exists {
+		panic(fmt.Sprintf("credentials type %q is already registered", typeName))
+	}
+	registry.types[typeName] = decoder
+}
+
+// fromMap implements HostCredentialsFromMap.
+func (r *credentialsRegistry) fromMap(m map[string]interface{}) HostCredentials {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// A "type" that isn't registered might be unrelated to this package, so
+	// we ignore it, as for any other unfamiliar key.
+	if typeName, ok := m[credentialsTypeAttr].(string); ok {
+		if decoder, ok := r.types[typeName]; ok {
+			return decoder.FromMap(m)
+		}
+	}
+
+	for _, rk := range r.keys {
+		if _, exists := m[rk.key]; !exists {
+			continue
+		}
+		if creds := rk.decoder.FromMap(m); creds != nil {
+			return creds
+		}
+	}
+	return nil
+}
+
+// fromObject implements HostCredentialsFromObject.
+func (r *credentialsRegistry) fromObject(obj cty.Value) HostCredentials {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if typeName, ok := stringAttr(obj, credentialsTypeAttr); ok {
+		if decoder, ok := r.types[typeName]; ok {
+			return decoder.FromObject(obj)
+		}
+	}
+
+	for _, rk := range r.keys {
+		if !obj.Type().HasAttribute(rk.key) {
+			continue
+		}
+		if creds := rk.decoder.FromObject(obj); creds != nil {
+			return creds
+		}
+	}
+	return nil
+}
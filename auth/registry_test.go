@@ -0,0 +1,142 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testAPIKeyDecoder is a HostCredentialsDecoder for a hypothetical
+// downstream credentials kind, used to test the registry.
+type testAPIKeyDecoder struct{}
+
+func (testAPIKeyDecoder) FromMap(m map[string]interface{}) HostCredentials {
+	if key, ok := m["test_api_key"].(string); ok {
+		return HostCredentialsHeaders{"X-Api-Key": key}
+	}
+	return nil
+}
+
+func (testAPIKeyDecoder) FromObject(obj cty.Value) HostCredentials {
+	if key, ok := stringAttr(obj, "test_api_key"); ok {
+		return HostCredentialsHeaders{"X-Api-Key": key}
+	}
+	return nil
+}
+
+func init() {
+	RegisterCredentialsKey("test_api_key", testAPIKeyDecoder{})
+	RegisterCredentialsType("test_api_key", testAPIKeyDecoder{})
+}
+
+func TestCredentialsRegistry(t *testing.T) {
+	tests := map[string]struct {
+		m    map[string]interface{}
+		want HostCredentials
+	}{
+		"registered key": {
+			map[string]interface{}{"test_api_key": "abc123"},
+			HostCredentialsHeaders{"X-Api-Key": "abc123"},
+		},
+		"built-in key takes precedence": {
+			map[string]interface{}{
+				"test_api_key": "abc123",
+				"token":        "def456",
+			},
+			HostCredentialsToken("def456"),
+		},
+		"registered type": {
+			map[string]interface{}{
+				"type":         "test_api_key",
+				"test_api_key": "abc123",
+				"token":        "def456",
+			},
+			HostCredentialsHeaders{"X-Api-Key": "abc123"},
+		},
+		"built-in type": {
+			map[string]interface{}{
+				"type":     "basic",
+				"token":    "def456",
+				"username": "alfred",
+				"password": "hunter2",
+			},
+			HostCredentialsBasic{Username: "alfred", Password: "hunter2"},
+		},
+		"type without required attributes": {
+			map[string]interface{}{
+				"type":  "basic",
+				"token": "def456",
+			},
+			nil,
+		},
+		"unregistered type": {
+			map[string]interface{}{
+				"type":  "certificate",
+				"token": "def456",
+			},
+			HostCredentialsToken("def456"),
+		},
+		"non-string type": {
+			map[string]interface{}{
+				"type":  12,
+				"token": "def456",
+			},
+			HostCredentialsToken("def456"),
+		},
+		"null type": {
+			map[string]interface{}{
+				"type":  nil,
+				"token": "def456",
+			},
+			HostCredentialsToken("def456"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := HostCredentialsFromMap(test.m)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result from map\n%s", diff)
+			}
+
+			obj := testObjectFromMap(t, test.m)
+			got = HostCredentialsFromObject(obj)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result from object\n%s", diff)
+			}
+		})
+	}
+}
+
+// testObjectFromMap converts a flat map of test credentials into an
+// equivalent cty object value.
+func testObjectFromMap(t *testing.T, m map[string]interface{}) cty.Value {
+	t.Helper()
+
+	attrs := make(map[string]cty.Value, len(m))
+	for k, v := range m {
+		switch v := v.(type) {
+		case nil:
+			attrs[k] = cty.NullVal(cty.String)
+		case string:
+			attrs[k] = cty.StringVal(v)
+		case int:
+			attrs[k] = cty.NumberIntVal(int64(v))
+		default:
+			t.Fatalf("unsupported test value %#v", v)
+		}
+	}
+	return cty.ObjectVal(attrs)
+}
+
+func TestRegisterCredentialsKey_duplicate(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("registering a duplicate key did not panic")
+		}
+	}()
+	RegisterCredentialsKey("token", testAPIKeyDecoder{})
+}
@@ -0,0 +1,187 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// CredentialsScope identifies a particular service or URL path prefix on a
+// host, for credentials sources that can hold different credentials for
+// different services provided by the same host.
+//
+// A scope with neither ServiceID nor Path set represents the whole host.
+type CredentialsScope struct {
+	Host svchost.Hostname
+
+	// ServiceID is a service identifier of the form "servicename.vN", as
+	// used with disco.Host.ServiceURL, or an empty string for no specific
+	// service.
+	ServiceID string
+
+	// Path is a URL path, or an empty string for no specific path. When
+	// looking up credentials, this is matched against path prefixes.
+	Path string
+}
+
+// ScopeForURL returns a CredentialsScope for the host and path of the given
+// absolute URL, such as a URL returned from disco.Host.ServiceURL.
+func ScopeForURL(u *url.URL) (CredentialsScope, error) {
+	if u == nil || u.Host == "" {
+		return CredentialsScope{}, fmt.Errorf("URL must be absolute")
+	}
+	host, err := svchost.ForComparison(u.Host)
+	if err != nil {
+		return CredentialsScope{}, fmt.Errorf("URL has invalid hostname: %w", err)
+	}
+	return CredentialsScope{
+		Host: host,
+		Path: u.Path,
+	}, nil
+}
+
+// ScopedCredentialsSource is an optional extension of CredentialsSource for
+// sources that can hold credentials for particular services or URL paths on
+// a host, in addition to credentials for a whole host.
+type ScopedCredentialsSource interface {
+	CredentialsSource
+
+	// ForScope returns the most specific credentials available for the given
+	// scope, or nil if there are none.
+	//
+	// Credentials for the scope's service ID take precedence over credentials
+	// for a prefix of its path, with longer prefixes taking precedence over
+	// shorter ones, and all of these take precedence over credentials for the
+	// scope's whole host.
+	ForScope(scope CredentialsScope) (HostCredentials, error)
+}
+
+// CredentialsForScope returns the most specific credentials available in the
+// given source for the given scope.
+//
+// If the source does not implement ScopedCredentialsSource then this falls
+// back to looking up credentials for the scope's whole host.
+func CredentialsForScope(source CredentialsSource, scope CredentialsScope) (HostCredentials, error) {
+	if scoped, ok := source.(ScopedCredentialsSource); ok {
+		return scoped.ForScope(scope)
+	}
+	return source.ForHost(scope.Host)
+}
+
+// ForScope iterates over the contained CredentialsSource objects and tries
+// to obtain credentials for the given scope from each one in turn, using
+// CredentialsForScope.
+//
+// If any source returns either a non-nil HostCredentials or a non-nil error
+// then this result is returned. Otherwise, the result is nil, nil.
+func (c Credentials) ForScope(scope CredentialsScope) (HostCredentials, error) {
+	for _, source := range c {
+		creds, err := CredentialsForScope(source, scope)
+		if creds != nil || err != nil {
+			return creds, err
+		}
+	}
+	return nil, nil
+}
+
+// StaticScopedCredentialsSource is like StaticCredentialsSource, but allows
+// each set of credentials to be associated with a particular service or URL
+// path prefix on a host, in addition to whole hosts.
+//
+// Keys with neither ServiceID nor Path set provide the credentials returned
+// from ForHost. The Host of each key may be a wildcard host pattern, as
+// for StaticCredentialsSource, and credentials for an exact hostname always
+// take precedence over those for a pattern. Path prefixes match only whole
+// path segments, so a prefix of "/state" matches "/state" and "/state/v2" but
+// not "/statement".
+//
+// The caller should not modify the given map after passing it to this
+// function.
+func StaticScopedCredentialsSource(creds map[CredentialsScope]map[string]interface{}) CredentialsSource {
+	return staticScopedCredentialsSource(creds)
+}
+
+type staticScopedCredentialsSource map[CredentialsScope]map[string]interface{}
+
+var _ ScopedCredentialsSource = staticScopedCredentialsSource(nil)
+
+func (s staticScopedCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForScope(CredentialsScope{Host: host})
+}
+
+func (s staticScopedCredentialsSource) ForScope(scope CredentialsScope) (HostCredentials, error) {
+	var best CredentialsScope
+	found := false
+	for candidate := range s {
+		if !candidate.contains(scope) {
+			continue
+		}
+		if !found || candidate.moreSpecificThan(best) {
+			best = candidate
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return HostCredentialsFromMap(s[best]), nil
+}
+
+func (s staticScopedCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	return fmt.Errorf("can't store new credentials in a static credentials source")
+}
+
+func (s staticScopedCredentialsSource) ForgetForHost(host svchost.Hostname) error {
+	return fmt.Errorf("can't discard credentials from a static credentials source")
+}
+
+// contains returns true if credentials for the receiving scope are
+// applicable to the given scope.
+func (s CredentialsScope) contains(other CredentialsScope) bool {
+	if !hostPatternMatches(s.Host, other.Host) {
+		return false
+	}
+	if s.ServiceID != "" && s.ServiceID != other.ServiceID {
+		return false
+	}
+	if s.Path != "" && !pathHasPrefix(other.Path, s.Path) {
+		return false
+	}
+	return true
+}
+
+// moreSpecificThan returns true if the receiver is a more specific scope
+// than the given scope, assuming that both contain the same target scope.
+func (s CredentialsScope) moreSpecificThan(other CredentialsScope) bool {
+	if s.Host != other.Host {
+		return hostPatternMoreSpecific(s.Host, other.Host)
+	}
+	if (s.ServiceID != "") != (other.ServiceID != "") {
+		return s.ServiceID != ""
+	}
+	sLen := len(strings.TrimSuffix(s.Path, "/"))
+	otherLen := len(strings.TrimSuffix(other.Path, "/"))
+	if sLen != otherLen {
+		return sLen > otherLen
+	}
+	// Scopes of equal specificity can differ only by a trailing slash, so
+	// we make an arbitrary but consistent choice between them.
+	return s.Path > other.Path
+}
+
+// pathHasPrefix returns true if the given path starts with the given prefix
+// on a path segment boundary.
+func pathHasPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
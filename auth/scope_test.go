@@ -0,0 +1,201 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestStaticScopedCredentialsSource(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	src := StaticScopedCredentialsSource(map[CredentialsScope]map[string]interface{}{
+		{Host: host}:                                                    {"token": "host"},
+		{Host: host, Path: "/state"}:                                    {"token": "state"},
+		{Host: host, Path: "/state/v2/"}:                                {"token": "state-v2"},
+		{Host: host, ServiceID: "modules.v1"}:                           {"token": "modules"},
+		{Host: host, ServiceID: "modules.v1", Path: "/modules/private"}: {"token": "private-modules"},
+	})
+
+	tests := map[string]struct {
+		scope CredentialsScope
+		want  HostCredentials
+	}{
+		"whole host": {
+			CredentialsScope{Host: host},
+			HostCredentialsToken("host"),
+		},
+		"unmatched path": {
+			CredentialsScope{Host: host, Path: "/other"},
+			HostCredentialsToken("host"),
+		},
+		"exact path": {
+			CredentialsScope{Host: host, Path: "/state"},
+			HostCredentialsToken("state"),
+		},
+		"path beneath prefix": {
+			CredentialsScope{Host: host, Path: "/state/v1/foo"},
+			HostCredentialsToken("state"),
+		},
+		"longest prefix": {
+			CredentialsScope{Host: host, Path: "/state/v2/foo"},
+			HostCredentialsToken("state-v2"),
+		},
+		"prefix without trailing slash": {
+			CredentialsScope{Host: host, Path: "/state/v2"},
+			HostCredentialsToken("state-v2"),
+		},
+		"prefix not on segment boundary": {
+			CredentialsScope{Host: host, Path: "/statement"},
+			HostCredentialsToken("host"),
+		},
+		"service ID beats path": {
+			CredentialsScope{Host: host, ServiceID: "modules.v1", Path: "/state/v2/foo"},
+			HostCredentialsToken("modules"),
+		},
+		"service ID and path": {
+			CredentialsScope{Host: host, ServiceID: "modules.v1", Path: "/modules/private/foo"},
+			HostCredentialsToken("private-modules"),
+		},
+		"other service ID": {
+			CredentialsScope{Host: host, ServiceID: "providers.v1", Path: "/modules/private/foo"},
+			HostCredentialsToken("host"),
+		},
+		"other host": {
+			CredentialsScope{Host: svchost.Hostname("example.net"), Path: "/state"},
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := CredentialsForScope(src, test.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCredentialsForScope(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	unscoped := StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+		host: {"token": "unscoped"},
+	})
+	scoped := StaticScopedCredentialsSource(map[CredentialsScope]map[string]interface{}{
+		{Host: host, Path: "/scoped"}: {"token": "scoped"},
+	})
+
+	t.Run("unscoped source", func(t *testing.T) {
+		got, err := CredentialsForScope(unscoped, CredentialsScope{Host: host, Path: "/scoped"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got, HostCredentialsToken("unscoped"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+	t.Run("credentials set", func(t *testing.T) {
+		src := Credentials{scoped, unscoped}
+		got, err := CredentialsForScope(src, CredentialsScope{Host: host, Path: "/scoped/foo"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got, HostCredentialsToken("scoped"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+
+		got, err = CredentialsForScope(src, CredentialsScope{Host: host, Path: "/other"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got, HostCredentialsToken("unscoped"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+	t.Run("caching source", func(t *testing.T) {
+		src := CachingCredentialsSource(Credentials{scoped, unscoped})
+		got, err := CredentialsForScope(src, CredentialsScope{Host: host, Path: "/scoped"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got, HostCredentialsToken("scoped"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+
+		got, err = src.ForHost(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := got, HostCredentialsToken("unscoped"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+	})
+}
+
+func TestScopeForURL(t *testing.T) {
+	u, err := url.Parse("https://Example.COM:8443/api/v1/state/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ScopeForURL(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CredentialsScope{
+		Host: svchost.Hostname("example.com:8443"),
+		Path: "/api/v1/state/",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong result\n%s", diff)
+	}
+
+	if _, err := ScopeForURL(&url.URL{Path: "/relative"}); err == nil {
+		t.Errorf("no error for relative URL")
+	}
+}
+
+func TestTransport_scoped(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	host := testServerHostname(t, server)
+	client := &http.Client{
+		Transport: &Transport{
+			Source: StaticScopedCredentialsSource(map[CredentialsScope]map[string]interface{}{
+				{Host: host}:                  {"token": "host"},
+				{Host: host, Path: "/state/"}: {"token": "state"},
+			}),
+			Base: server.Client().Transport,
+		},
+	}
+
+	for path, want := range map[string]string{
+		"/modules":     "Bearer host",
+		"/state/foo":   "Bearer state",
+		"/statefile/x": "Bearer host",
+	} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := client.Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if gotAuth != want {
+				t.Errorf("wrong Authorization header %q; want %q", gotAuth, want)
+			}
+		})
+	}
+}
@@ -12,6 +12,17 @@ import (
 // from the provided map. It returns nil if a requested hostname is not
 // present in the map.
 //
+// Keys may also be wildcard host patterns such as "*.example.com", which
+// match any hostname with one or more additional labels before the given
+// suffix, such as "foo.example.com" or "foo.bar.example.com", but not
+// "example.com" itself. A pattern with a port number matches only hostnames
+// with that same port, and a pattern without one matches only hostnames
+// without a port. The suffix must be written in the form that
+// svchost.ForComparison would return.
+//
+// An exact hostname key always takes precedence over a wildcard pattern, and
+// longer patterns take precedence over shorter ones.
+//
 // The caller should not modify the given map after passing it to this function.
 func StaticCredentialsSource(creds map[svchost.Hostname]map[string]interface{}) CredentialsSource {
 	return staticCredentialsSource(creds)
@@ -24,7 +35,7 @@ func (s staticCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials
 		return nil, nil
 	}
 
-	if m, exists := s[host]; exists {
+	if m, exists := lookupHostPattern(s, host); exists {
 		return HostCredentialsFromMap(m), nil
 	}
 
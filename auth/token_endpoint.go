@@ -0,0 +1,221 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// tokenCacheSkew is how long before their expiry time that tokenCache
+// considers credentials to be expired, so that a token is never sent just
+// as it expires.
+const tokenCacheSkew = time.Minute
+
+// tokenCache is an in-memory per-hostname cache of credentials obtained
+// from a token endpoint, for credentials sources that obtain new tokens
+// rather than reading them from a store.
+type tokenCache struct {
+	// now is overridden during tests to simulate the passage of time.
+	now func() time.Time
+
+	// must lock "mu" while interacting with tokens or hostMu
+	tokens map[svchost.Hostname]HostCredentials
+	mu     sync.Mutex
+
+	// hostMu has a channel for each host that acts as a lock, held while
+	// obtaining new credentials for that host. A channel, rather than a
+	// mutex, allows callers to stop waiting when their context is
+	// cancelled.
+	hostMu map[svchost.Hostname]chan struct{}
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		now:    time.Now,
+		tokens: map[svchost.Hostname]HostCredentials{},
+		hostMu: map[svchost.Hostname]chan struct{}{},
+	}
+}
+
+// get returns the cached credentials for the given host if there are any and
+// they are not about to expire, or otherwise calls the given function to
+// obtain new credentials and caches them.
+//
+// Only one caller at a time obtains new credentials for each host, so that
+// concurrent callers will not obtain new credentials for the same host more
+// than once. Callers waiting for another to finish stop waiting if the given
+// context is cancelled. Callers for different hosts don't wait for each
+// other.
+func (c *tokenCache) get(ctx context.Context, host svchost.Hostname, fetch func() (HostCredentials, error)) (HostCredentials, error) {
+	if creds, ok := c.cached(host); ok {
+		return creds, nil
+	}
+
+	c.mu.Lock()
+	lock, ok := c.hostMu[host]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		c.hostMu[host] = lock
+	}
+	c.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		defer func() { <-lock }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Another caller may have obtained new credentials while we waited.
+	if creds, ok := c.cached(host); ok {
+		return creds, nil
+	}
+
+	creds, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if creds != nil {
+		c.tokens[host] = creds
+	} else {
+		delete(c.tokens, host)
+	}
+	c.mu.Unlock()
+	return creds, nil
+}
+
+// cached returns the cached credentials for the given host, if there are
+// any that are not about to expire.
+func (c *tokenCache) cached(host svchost.Hostname) (HostCredentials, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	creds, ok := c.tokens[host]
+	if !ok || !c.fresh(creds) {
+		return nil, false
+	}
+	return creds, true
+}
+
+// invalidate discards any cached credentials for the given host.
+func (c *tokenCache) invalidate(host svchost.Hostname) {
+	c.mu.Lock()
+	delete(c.tokens, host)
+	c.mu.Unlock()
+}
+
+// fresh returns true if the given credentials have no known expiry time, or
+// if their expiry time is more than tokenCacheSkew in the future.
+func (c *tokenCache) fresh(creds HostCredentials) bool {
+	expiry, ok := credentialsExpiry(creds)
+	return !ok || c.now().Add(tokenCacheSkew).Before(expiry)
+}
+
+// hostInPatterns returns true if the given hostname matches any of the given
+// hostnames or wildcard host patterns. It returns false if there are no
+// patterns at all, so that credentials sources that obtain tokens from a
+// token endpoint only send them, and any secrets used to obtain them, to
+// hosts that were explicitly configured.
+func hostInPatterns(host svchost.Hostname, patterns []svchost.Hostname) bool {
+	for _, pattern := range patterns {
+		if hostPatternMatches(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenResponse is the JSON representation of a successful response from an
+// OAuth token endpoint, as defined in IETF RFC 6749 section 5.1.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// tokenErrorResponse is the JSON representation of an error response from
+// an OAuth token endpoint, as defined in IETF RFC 6749 section 5.2.
+type tokenErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// requestToken posts the given form to the given OAuth token endpoint and
+// returns the access token from the response. If clientAuth is not nil, it
+// is called to add client authentication to the request before it is sent.
+//
+// The endpoint must use HTTPS, because the request contains secrets.
+func requestToken(ctx context.Context, client *http.Client, endpoint *url.URL, form url.Values, clientAuth func(*http.Request)) (HostCredentialsOAuth2, error) {
+	if endpoint.Scheme != "https" {
+		return HostCredentialsOAuth2{}, fmt.Errorf("token endpoint %s must use https", endpoint.Redacted())
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return HostCredentialsOAuth2{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientAuth != nil {
+		clientAuth(req)
+	}
+
+	requested := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return HostCredentialsOAuth2{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return HostCredentialsOAuth2{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "application/json" {
+		return HostCredentialsOAuth2{}, fmt.Errorf("unexpected response %s with content type %q", resp.Status, mediaType)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp tokenErrorResponse
+		if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == "" {
+			return HostCredentialsOAuth2{}, fmt.Errorf("unexpected response %s", resp.Status)
+		}
+		if errResp.ErrorDescription != "" {
+			return HostCredentialsOAuth2{}, fmt.Errorf("server returned error %q: %s", errResp.Error, errResp.ErrorDescription)
+		}
+		return HostCredentialsOAuth2{}, fmt.Errorf("server returned error %q", errResp.Error)
+	}
+
+	var tokResp tokenResponse
+	if err := json.Unmarshal(body, &tokResp); err != nil {
+		return HostCredentialsOAuth2{}, fmt.Errorf("invalid response: %w", err)
+	}
+	if tokResp.AccessToken == "" {
+		return HostCredentialsOAuth2{}, errors.New("response has no access token")
+	}
+	if tokResp.TokenType != "" && !strings.EqualFold(tokResp.TokenType, "bearer") && !strings.EqualFold(tokResp.TokenType, "N_A") {
+		return HostCredentialsOAuth2{}, fmt.Errorf("unsupported token type %q", tokResp.TokenType)
+	}
+
+	creds := HostCredentialsOAuth2{AccessToken: tokResp.AccessToken}
+	if tokResp.ExpiresIn > 0 {
+		// We measure the lifetime from when we sent the request, so that the
+		// time spent waiting for the response can't extend it.
+		creds.Expiry = requested.Add(time.Duration(tokResp.ExpiresIn) * time.Second)
+	}
+	return creds, nil
+}
@@ -0,0 +1,58 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestTokenCache_concurrent(t *testing.T) {
+	cache := newTokenCache()
+	fetch := func(token string) func() (HostCredentials, error) {
+		return func() (HostCredentials, error) {
+			return HostCredentialsToken(token), nil
+		}
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cache.get(context.Background(), "slow.example.com", func() (HostCredentials, error) {
+			close(started)
+			<-release
+			return HostCredentialsToken("slow"), nil
+		})
+	}()
+	defer func() {
+		close(release)
+		<-done
+	}()
+	<-started
+
+	t.Run("other host", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		creds, err := cache.get(ctx, "fast.example.com", fetch("fast"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds.Token(), "fast"; got != want {
+			t.Errorf("wrong token %q; want %q", got, want)
+		}
+	})
+	t.Run("same host", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := cache.get(ctx, svchost.Hostname("slow.example.com"), fetch("duplicate"))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wrong error %v; want context.DeadlineExceeded", err)
+		}
+	})
+}
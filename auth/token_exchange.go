@@ -0,0 +1,195 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// Token type identifiers for use with TokenExchangeConfig, as defined in
+// IETF RFC 8693 section 3.
+const (
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+	TokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// tokenExchangeGrantType is the OAuth grant type for token exchange, as
+// defined in IETF RFC 8693 section 2.1.
+const tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// TokenExchangeConfig is the configuration for TokenExchangeCredentialsSource.
+type TokenExchangeConfig struct {
+	// SubjectTokenFile is the path of a file containing the subject token
+	// to exchange, such as an OIDC ID token issued to a CI job. The file is
+	// read again for each exchange, so that it can be updated while the
+	// source is in use.
+	SubjectTokenFile string
+
+	// SubjectTokenEnv is the name of an environment variable containing the
+	// subject token, used if SubjectTokenFile is not set.
+	SubjectTokenEnv string
+
+	// SubjectTokenType is the type of the subject token. If it is empty,
+	// TokenTypeJWT is used.
+	SubjectTokenType string
+
+	// Endpoint is the URL of the token endpoint to use for all hosts. If it
+	// is nil, EndpointForHost is used to find the endpoint for each host.
+	Endpoint *url.URL
+
+	// EndpointForHost returns the URL of the token endpoint to use for the
+	// given host, or nil if the host does not support token exchange. For
+	// example, disco.Disco.TokenExchangeEndpoint finds the endpoint using
+	// service discovery. It is called only for hosts that match Hosts.
+	//
+	// Whichever way it is found, the endpoint must use HTTPS.
+	EndpointForHost func(host svchost.Hostname) (*url.URL, error)
+
+	// Hosts are the hostnames or wildcard host patterns, as described for
+	// StaticCredentialsSource, for which the source will exchange tokens.
+	// The source has no credentials for any other host, and so if Hosts is
+	// empty it has no credentials at all. This prevents the subject token
+	// from being sent to an endpoint advertised by an arbitrary host.
+	Hosts []svchost.Hostname
+
+	// Audience is the logical name of the service for which a token is
+	// requested. If it is empty, the hostname in ASCII compatibility form
+	// (punycode form) is used.
+	Audience string
+
+	// Scopes are the scopes to request, if any.
+	Scopes []string
+
+	// HTTPClient is the client to use for requests to the token endpoint. If
+	// it is nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+type tokenExchangeCredentialsSource struct {
+	config TokenExchangeConfig
+	tokens *tokenCache
+}
+
+// TokenExchangeCredentialsSource returns a CredentialsSource that obtains
+// short-lived credentials for each host by exchanging a subject token, such
+// as a CI system's OIDC identity token, at a token endpoint using the
+// protocol defined in IETF RFC 8693.
+//
+// The resulting access tokens are returned as HostCredentialsOAuth2 values
+// and cached in memory until shortly before they expire, at which point a
+// new exchange is performed. Credentials can't be stored in or forgotten
+// from this source.
+func TokenExchangeCredentialsSource(config TokenExchangeConfig) CredentialsSource {
+	return &tokenExchangeCredentialsSource{
+		config: config,
+		tokens: newTokenCache(),
+	}
+}
+
+var _ CredentialsInvalidator = (*tokenExchangeCredentialsSource)(nil)
+
+func (s *tokenExchangeCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.ForHostContext(context.Background(), host)
+}
+
+// ForHostContext implements ContextCredentialsSource, aborting any request
+// to the token endpoint if the given context is cancelled.
+func (s *tokenExchangeCredentialsSource) ForHostContext(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	if !hostInPatterns(host, s.config.Hosts) {
+		return nil, nil
+	}
+	return s.tokens.get(ctx, host, func() (HostCredentials, error) {
+		return s.exchange(ctx, host)
+	})
+}
+
+func (s *tokenExchangeCredentialsSource) StoreForHost(host svchost.Hostname, credentials HostCredentialsWritable) error {
+	return fmt.Errorf("can't store new credentials in a token exchange credentials source")
+}
+
+func (s *tokenExchangeCredentialsSource) ForgetForHost(host svchost.Hostname) error {
+	return fmt.Errorf("can't discard credentials from a token exchange credentials source")
+}
+
+// InvalidateForHost discards the cached token for the given host, so that
+// the next request for credentials performs a new exchange.
+func (s *tokenExchangeCredentialsSource) InvalidateForHost(host svchost.Hostname) {
+	s.tokens.invalidate(host)
+}
+
+func (s *tokenExchangeCredentialsSource) exchange(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	endpoint := s.config.Endpoint
+	if endpoint == nil && s.config.EndpointForHost != nil {
+		var err error
+		endpoint, err = s.config.EndpointForHost(host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find token exchange endpoint for %s: %w", host.ForDisplay(), err)
+		}
+	}
+	if endpoint == nil {
+		return nil, nil
+	}
+
+	subjectToken, err := s.subjectToken()
+	if err != nil {
+		return nil, err
+	}
+
+	subjectTokenType := s.config.SubjectTokenType
+	if subjectTokenType == "" {
+		subjectTokenType = TokenTypeJWT
+	}
+	audience := s.config.Audience
+	if audience == "" {
+		audience = string(host)
+	}
+	form := url.Values{
+		"grant_type":           {tokenExchangeGrantType},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {subjectTokenType},
+		"requested_token_type": {TokenTypeAccessToken},
+		"audience":             {audience},
+	}
+	if len(s.config.Scopes) != 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	log.Printf("[DEBUG] Exchanging token for %s at %s", host, endpoint)
+	creds, err := requestToken(ctx, s.config.HTTPClient, endpoint, form, nil)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange for %s failed: %w", host.ForDisplay(), err)
+	}
+	return creds, nil
+}
+
+// subjectToken returns the subject token from the configured file or
+// environment variable.
+func (s *tokenExchangeCredentialsSource) subjectToken() (string, error) {
+	var token string
+	switch {
+	case s.config.SubjectTokenFile != "":
+		raw, err := os.ReadFile(s.config.SubjectTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read subject token: %w", err)
+		}
+		token = strings.TrimSpace(string(raw))
+	case s.config.SubjectTokenEnv != "":
+		token = strings.TrimSpace(os.Getenv(s.config.SubjectTokenEnv))
+	default:
+		return "", errors.New("no subject token file or environment variable is configured")
+	}
+	if token == "" {
+		return "", errors.New("subject token is empty")
+	}
+	return token, nil
+}
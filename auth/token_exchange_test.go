@@ -0,0 +1,213 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestTokenExchangeCredentialsSource(t *testing.T) {
+	exchanges := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		w.Header().Set("Content-Type", "application/json")
+		if got, want := r.FormValue("grant_type"), "urn:ietf:params:oauth:grant-type:token-exchange"; got != want {
+			t.Errorf("wrong grant_type %q; want %q", got, want)
+		}
+		if got, want := r.FormValue("subject_token_type"), TokenTypeJWT; got != want {
+			t.Errorf("wrong subject_token_type %q; want %q", got, want)
+		}
+		if got, want := r.FormValue("scope"), "read write"; got != want {
+			t.Errorf("wrong scope %q; want %q", got, want)
+		}
+		if r.FormValue("subject_token") != "ci-oidc-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"subject token is not trusted"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"exchanged-for-` + r.FormValue("audience") + `","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":600}`))
+	}))
+	defer server.Close()
+	endpoint, err := url.Parse(server.URL + "/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("ci-oidc-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("from file", func(t *testing.T) {
+		exchanges = 0
+		src := TokenExchangeCredentialsSource(TokenExchangeConfig{
+			SubjectTokenFile: tokenFile,
+			Endpoint:         endpoint,
+			Hosts:            []svchost.Hostname{"*.example.com"},
+			Scopes:           []string{"read", "write"},
+			HTTPClient:       server.Client(),
+		}).(*tokenExchangeCredentialsSource)
+		now := time.Now()
+		src.tokens.now = func() time.Time { return now }
+
+		creds, err := src.ForHost("app.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		oc, ok := creds.(HostCredentialsOAuth2)
+		if !ok {
+			t.Fatalf("wrong type of credentials %T", creds)
+		}
+		if got, want := oc.AccessToken, "exchanged-for-app.example.com"; got != want {
+			t.Errorf("wrong access token %q; want %q", got, want)
+		}
+		if oc.Expiry.IsZero() {
+			t.Errorf("credentials have no expiry time")
+		}
+
+		// The token is cached until shortly before it expires.
+		if _, err := src.ForHost("app.example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := exchanges, 1; got != want {
+			t.Errorf("wrong number of exchanges %d; want %d", got, want)
+		}
+		now = now.Add(590 * time.Second)
+		if _, err := src.ForHost("app.example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := exchanges, 2; got != want {
+			t.Errorf("wrong number of exchanges %d; want %d", got, want)
+		}
+
+		// Invalidating the host forces a new exchange.
+		src.InvalidateForHost("app.example.com")
+		if _, err := src.ForHost("app.example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := exchanges, 3; got != want {
+			t.Errorf("wrong number of exchanges %d; want %d", got, want)
+		}
+
+		// Hosts that don't match any pattern have no credentials.
+		creds, err = src.ForHost("example.net")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials for unconfigured host; want nil")
+		}
+	})
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv("TEST_SUBJECT_TOKEN", "ci-oidc-token")
+		src := TokenExchangeCredentialsSource(TokenExchangeConfig{
+			SubjectTokenEnv: "TEST_SUBJECT_TOKEN",
+			EndpointForHost: func(host svchost.Hostname) (*url.URL, error) {
+				if host == "example.com" {
+					return endpoint, nil
+				}
+				return nil, nil
+			},
+			Hosts:      []svchost.Hostname{"example.com", "other.example.com"},
+			Audience:   "registry",
+			Scopes:     []string{"read", "write"},
+			HTTPClient: server.Client(),
+		})
+
+		creds, err := src.ForHost("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := creds.Token(), "exchanged-for-registry"; got != want {
+			t.Errorf("wrong access token %q; want %q", got, want)
+		}
+
+		creds, err = src.ForHost("other.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials for host without endpoint; want nil")
+		}
+	})
+	t.Run("rejected", func(t *testing.T) {
+		t.Setenv("TEST_SUBJECT_TOKEN", "untrusted")
+		src := TokenExchangeCredentialsSource(TokenExchangeConfig{
+			SubjectTokenEnv: "TEST_SUBJECT_TOKEN",
+			Endpoint:        endpoint,
+			Hosts:           []svchost.Hostname{"example.com"},
+			Scopes:          []string{"read", "write"},
+			HTTPClient:      server.Client(),
+		})
+
+		_, err := src.ForHost("example.com")
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if got, want := err.Error(), "subject token is not trusted"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want message containing %q", got, want)
+		}
+	})
+	t.Run("no subject token", func(t *testing.T) {
+		src := TokenExchangeCredentialsSource(TokenExchangeConfig{
+			SubjectTokenEnv: "TEST_SUBJECT_TOKEN_UNSET",
+			Endpoint:        endpoint,
+			Hosts:           []svchost.Hostname{"example.com"},
+			HTTPClient:      server.Client(),
+		})
+
+		_, err := src.ForHost("example.com")
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+	})
+	t.Run("no hosts", func(t *testing.T) {
+		t.Setenv("TEST_SUBJECT_TOKEN", "ci-oidc-token")
+		exchanges = 0
+		src := TokenExchangeCredentialsSource(TokenExchangeConfig{
+			SubjectTokenEnv: "TEST_SUBJECT_TOKEN",
+			EndpointForHost: func(host svchost.Hostname) (*url.URL, error) {
+				return endpoint, nil
+			},
+			HTTPClient: server.Client(),
+		})
+
+		creds, err := src.ForHost("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials without any configured hosts; want nil")
+		}
+		if exchanges != 0 {
+			t.Errorf("exchanged subject token without any configured hosts")
+		}
+	})
+	t.Run("insecure endpoint", func(t *testing.T) {
+		t.Setenv("TEST_SUBJECT_TOKEN", "ci-oidc-token")
+		insecure := *endpoint
+		insecure.Scheme = "http"
+		src := TokenExchangeCredentialsSource(TokenExchangeConfig{
+			SubjectTokenEnv: "TEST_SUBJECT_TOKEN",
+			Endpoint:        &insecure,
+			Hosts:           []svchost.Hostname{"example.com"},
+		})
+
+		_, err := src.ForHost("example.com")
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if got, want := err.Error(), "must use https"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want message containing %q", got, want)
+		}
+	})
+}
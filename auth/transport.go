@@ -0,0 +1,157 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// Transport is an http.RoundTripper that applies credentials from a
+// CredentialsSource to each outgoing request, based on the hostname in the
+// request URL.
+//
+// If the source implements ScopedCredentialsSource, the most specific
+// credentials for the request URL's path are used, as described for
+// CredentialsForScope.
+//
+// Credentials are looked up separately for every request, including each
+// request made while following redirects, and are applied only to a copy of
+// the request. Credentials for one host are therefore never sent to another
+// host, even if an http.Client copies the original request's headers when
+// following a redirect.
+//
+// Credentials are applied only to requests using the "https" scheme, so that
+// they cannot be intercepted in transit.
+type Transport struct {
+	// Source is the credentials source to consult for each request. If it
+	// is nil, requests are sent without credentials.
+	Source CredentialsSource
+
+	// Base is the RoundTripper used to make the requests after credentials
+	// are applied. If it is nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// ResolveHost, if set, is called with the hostname of each request URL
+	// to determine which hostname's credentials to use for it. This can be
+	// used to honor hostname aliases, such as those of disco.Disco.
+	//
+	// The function must return its argument unchanged if the hostname
+	// has no alias.
+	ResolveHost func(svchost.Hostname) svchost.Hostname
+
+	// Reauthenticate, if set, is called when a server responds to a request
+	// with status 401 Unauthorized, after any in-memory credentials for
+	// the host have been discarded using InvalidateForHost. It receives
+	// the hostname whose credentials were used, along with the challenges
+	// from the response's WWW-Authenticate headers.
+	//
+	// If the function returns nil, the request is retried once with newly
+	// looked-up credentials. This allows the function to obtain and store
+	// new credentials, for example. If it returns an error then the
+	// request fails with that error.
+	//
+	// Requests with a body are retried only if the request has a GetBody
+	// function, as described in the documentation for http.Request.
+	Reauthenticate func(host svchost.Hostname, challenges []AuthChallenge) error
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host, hasHost := t.hostForRequest(req)
+	if !hasHost {
+		return t.base().RoundTrip(req)
+	}
+
+	resp, err := t.roundTripWithCredentials(req, host)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	InvalidateForHost(t.Source, host)
+	if t.Reauthenticate == nil || !canRetryRequest(req) {
+		return resp, nil
+	}
+
+	challenges, err := ChallengesFromResponse(resp)
+	if err != nil {
+		// A malformed challenge shouldn't prevent re-authentication, since
+		// the status code alone tells us that the credentials were rejected.
+		log.Printf("[WARN] Ignoring %s", err)
+	}
+	resp.Body.Close()
+
+	if err := t.Reauthenticate(host, challenges); err != nil {
+		return nil, fmt.Errorf("failed to re-authenticate with %s: %w", host.ForDisplay(), err)
+	}
+
+	retryReq := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if retryReq.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("[DEBUG] Retrying request to %s after re-authentication", host)
+	return t.roundTripWithCredentials(retryReq, host)
+}
+
+// roundTripWithCredentials sends the given request with any credentials
+// available for the given host.
+func (t *Transport) roundTripWithCredentials(req *http.Request, host svchost.Hostname) (*http.Response, error) {
+	creds, err := CredentialsForScope(t.Source, CredentialsScope{
+		Host: host,
+		Path: req.URL.Path,
+	})
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("failed to retrieve credentials for %s: %w", host.ForDisplay(), err)
+	}
+	if creds == nil {
+		return t.base().RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the given request, so we apply the
+	// credentials to a copy of it.
+	authReq := req.Clone(req.Context())
+	creds.PrepareRequest(authReq)
+	return t.base().RoundTrip(authReq)
+}
+
+// canRetryRequest returns true if the given request can be sent again.
+func canRetryRequest(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// hostForRequest returns the hostname whose credentials should be applied
+// to the given request, or false if no credentials should be applied.
+func (t *Transport) hostForRequest(req *http.Request) (svchost.Hostname, bool) {
+	if t.Source == nil || req.URL == nil || req.URL.Scheme != "https" {
+		return "", false
+	}
+
+	host, err := svchost.ForComparison(req.URL.Host)
+	if err != nil {
+		// If the URL doesn't contain a valid service hostname then there
+		// can't be any credentials for it.
+		log.Printf("[TRACE] Not applying credentials to request for invalid hostname %q: %s", req.URL.Host, err)
+		return "", false
+	}
+	if t.ResolveHost != nil {
+		host = t.ResolveHost(host)
+	}
+	return host, true
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
@@ -0,0 +1,238 @@
+// Copyright IBM Corp. 2017, 2025
+
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestTransport(t *testing.T) {
+	var gotAuth map[string]string
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			gotAuth[name] = r.Header.Get("Authorization")
+		}
+	}
+
+	serverB := httptest.NewTLSServer(record("b"))
+	defer serverB.Close()
+	serverA := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record("a")(w, r)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, serverB.URL+"/target", http.StatusFound)
+		}
+	}))
+	defer serverA.Close()
+
+	hostA := testServerHostname(t, serverA)
+	hostB := testServerHostname(t, serverB)
+
+	creds := map[svchost.Hostname]map[string]interface{}{
+		hostA: {"token": "token-a"},
+	}
+	transport := &Transport{
+		Source: StaticCredentialsSource(creds),
+		Base:   serverA.Client().Transport,
+	}
+	client := &http.Client{Transport: transport}
+
+	t.Run("applies credentials for request host", func(t *testing.T) {
+		gotAuth = map[string]string{}
+		req, err := http.NewRequest("GET", serverA.URL+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := gotAuth["a"], "Bearer token-a"; got != want {
+			t.Errorf("wrong Authorization header %q; want %q", got, want)
+		}
+		if got := req.Header.Get("Authorization"); got != "" {
+			t.Errorf("original request was modified: Authorization is %q", got)
+		}
+	})
+	t.Run("does not leak credentials across redirect", func(t *testing.T) {
+		gotAuth = map[string]string{}
+		resp, err := client.Get(serverA.URL + "/redirect")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := gotAuth["a"], "Bearer token-a"; got != want {
+			t.Errorf("wrong Authorization header for first host %q; want %q", got, want)
+		}
+		if got, ok := gotAuth["b"]; !ok || got != "" {
+			t.Errorf("wrong Authorization header for redirect target %q; want none", got)
+		}
+	})
+	t.Run("applies redirect target's own credentials", func(t *testing.T) {
+		creds[hostB] = map[string]interface{}{"token": "token-b"}
+		defer delete(creds, hostB)
+
+		gotAuth = map[string]string{}
+		resp, err := client.Get(serverA.URL + "/redirect")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := gotAuth["b"], "Bearer token-b"; got != want {
+			t.Errorf("wrong Authorization header for redirect target %q; want %q", got, want)
+		}
+	})
+	t.Run("resolves host aliases", func(t *testing.T) {
+		aliased := &Transport{
+			Source: StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+				"target.example.com": {"token": "aliased"},
+			}),
+			Base: serverA.Client().Transport,
+			ResolveHost: func(h svchost.Hostname) svchost.Hostname {
+				if h == hostA {
+					return "target.example.com"
+				}
+				return h
+			},
+		}
+
+		gotAuth = map[string]string{}
+		resp, err := (&http.Client{Transport: aliased}).Get(serverA.URL + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := gotAuth["a"], "Bearer aliased"; got != want {
+			t.Errorf("wrong Authorization header %q; want %q", got, want)
+		}
+	})
+	t.Run("no credentials over plain HTTP", func(t *testing.T) {
+		plain := httptest.NewServer(record("plain"))
+		defer plain.Close()
+		creds[testServerHostname(t, plain)] = map[string]interface{}{"token": "secret"}
+
+		gotAuth = map[string]string{}
+		resp, err := (&http.Client{Transport: &Transport{Source: StaticCredentialsSource(creds)}}).Get(plain.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got := gotAuth["plain"]; got != "" {
+			t.Errorf("sent Authorization header %q over plain HTTP", got)
+		}
+	})
+}
+
+func TestTransport_reauthenticate(t *testing.T) {
+	var gotRequests []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotRequests = append(gotRequests, r.Header.Get("Authorization")+" "+string(body))
+		if r.Header.Get("Authorization") != "Bearer new-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="example", error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	host := testServerHostname(t, server)
+
+	mem := newTestMemorySource()
+	mem.creds[host] = HostCredentialsToken("old-token")
+	src := CachingCredentialsSource(mem)
+
+	var gotChallenges []AuthChallenge
+	transport := &Transport{
+		Source: src,
+		Base:   server.Client().Transport,
+		Reauthenticate: func(h svchost.Hostname, challenges []AuthChallenge) error {
+			if h != host {
+				t.Errorf("re-authentication for wrong host %s", h)
+			}
+			gotChallenges = challenges
+			mem.creds[host] = HostCredentialsToken("new-token")
+			return nil
+		},
+	}
+	client := &http.Client{Transport: transport}
+
+	t.Run("retries with new credentials", func(t *testing.T) {
+		gotRequests = nil
+		resp, err := client.Post(server.URL, "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("wrong status %d; want %d", got, want)
+		}
+		want := []string{"Bearer old-token hello", "Bearer new-token hello"}
+		if diff := cmp.Diff(want, gotRequests); diff != "" {
+			t.Errorf("wrong requests\n%s", diff)
+		}
+		if len(gotChallenges) != 1 || gotChallenges[0].BearerError() != BearerErrorInvalidToken {
+			t.Errorf("wrong challenges %#v", gotChallenges)
+		}
+	})
+	t.Run("retries only once", func(t *testing.T) {
+		mem.creds[host] = HostCredentialsToken("still-wrong")
+		src.(CredentialsInvalidator).InvalidateForHost(host)
+		transport.Reauthenticate = func(svchost.Hostname, []AuthChallenge) error {
+			return nil
+		}
+
+		gotRequests = nil
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Errorf("wrong status %d; want %d", got, want)
+		}
+		if got, want := len(gotRequests), 2; got != want {
+			t.Errorf("wrong number of requests %d; want %d", got, want)
+		}
+	})
+	t.Run("re-authentication error", func(t *testing.T) {
+		transport.Reauthenticate = func(svchost.Hostname, []AuthChallenge) error {
+			return errors.New("can't log in")
+		}
+
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("completed successfully; want error")
+		}
+	})
+}
+
+func testServerHostname(t *testing.T, server *httptest.Server) svchost.Hostname {
+	t.Helper()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, err := svchost.ForComparison(u.Host)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	return host
+}
@@ -0,0 +1,137 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"net/url"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// Cache is implemented by persistent stores for discovery results, which a
+// Disco created with the WithCache option uses in addition to its in-memory
+// cache, so that results can be reused across processes.
+//
+// A Disco consults its Cache only for hostnames that have no in-memory cache
+// entry, and writes to it after each successful network discovery. Errors
+// from a Cache are logged and otherwise ignored, so that a broken cache can
+// only make discovery slower, not make it fail.
+//
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the entry for the given hostname, or nil if there is none.
+	Get(hostname svchost.Hostname) (*CacheEntry, error)
+
+	// Put stores the given entry for the given hostname, replacing any
+	// existing entry.
+	Put(hostname svchost.Hostname, entry *CacheEntry) error
+
+	// Delete removes the entry for the given hostname. It does nothing if
+	// there is no such entry.
+	Delete(hostname svchost.Hostname) error
+}
+
+// CacheEntry is a discovery result as stored by a Cache.
+type CacheEntry struct {
+	// URL is the URL that the discovery document was retrieved from, after
+	// following any redirects. Relative service URLs are resolved against it.
+	URL *url.URL
+
+	// Services is the decoded discovery document, or nil if the host has
+	// no discovery document.
+	Services map[string]interface{}
+
+	// FetchedAt and ExpiresAt are the values of Host.FetchedAt and
+	// Host.ExpiresAt for the result.
+	FetchedAt time.Time
+	ExpiresAt time.Time
+
+	// ETag and LastModified are the validators from the discovery response,
+	// used to revalidate the result once it has expired. Either may be empty.
+	ETag         string
+	LastModified string
+}
+
+// cacheEntry returns a CacheEntry describing the receiver.
+func (h *Host) cacheEntry() *CacheEntry {
+	return &CacheEntry{
+		URL:          h.discoURL,
+		Services:     h.services,
+		FetchedAt:    h.fetchedAt,
+		ExpiresAt:    h.expiresAt,
+		ETag:         h.etag,
+		LastModified: h.lastModified,
+	}
+}
+
+// hostFromCacheEntry returns a Host for the given hostname built from the
+// given cache entry.
+func (d *Disco) hostFromCacheEntry(hostname svchost.Hostname, entry *CacheEntry) *Host {
+	return &Host{
+		discoURL:     entry.URL,
+		hostname:     d.ResolveAlias(hostname).ForDisplay(),
+		services:     entry.Services,
+		transport:    d.Transport,
+		logger:       d.logger,
+		fetchedAt:    entry.FetchedAt,
+		expiresAt:    entry.ExpiresAt,
+		etag:         entry.ETag,
+		lastModified: entry.LastModified,
+	}
+}
+
+// loadCached returns the result for the given hostname from the receiver's
+// persistent cache, or nil if there is no persistent cache or it has no
+// usable entry for the hostname.
+//
+// This must be called _without_ d.mu locked.
+func (d *Disco) loadCached(hostname svchost.Hostname) *Host {
+	if d.cache == nil {
+		return nil
+	}
+	entry, err := d.cache.Get(hostname)
+	if err != nil {
+		d.logger.Printf("[WARN] Failed to read cached discovery result for %s: %s (ignoring)", hostname, err)
+		return nil
+	}
+	if entry == nil || entry.URL == nil {
+		return nil
+	}
+	return d.hostFromCacheEntry(hostname, entry)
+}
+
+// storeCached writes the given result for the given hostname to the
+// receiver's persistent cache, if any, or removes the hostname's entry if
+// the result must not be stored.
+//
+// This must be called _without_ d.mu locked.
+func (d *Disco) storeCached(hostname svchost.Hostname, host *Host) {
+	if d.cache == nil {
+		return
+	}
+	var err error
+	if host.noStore {
+		err = d.cache.Delete(hostname)
+	} else {
+		err = d.cache.Put(hostname, host.cacheEntry())
+	}
+	if err != nil {
+		d.logger.Printf("[WARN] Failed to update cached discovery result for %s: %s (ignoring)", hostname, err)
+	}
+}
+
+// forgetCached removes the entries for the given hostnames from the
+// receiver's persistent cache, if any.
+//
+// This must be called _without_ d.mu locked.
+func (d *Disco) forgetCached(hostnames ...svchost.Hostname) {
+	if d.cache == nil {
+		return
+	}
+	for _, hostname := range hostnames {
+		if err := d.cache.Delete(hostname); err != nil {
+			d.logger.Printf("[WARN] Failed to remove cached discovery result for %s: %s (ignoring)", hostname, err)
+		}
+	}
+}
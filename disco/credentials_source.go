@@ -0,0 +1,56 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"context"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+	"github.com/hashicorp/terraform-svchost/auth"
+)
+
+// AliasedCredentialsSource returns a view of the receiver's credentials
+// source that resolves hostname aliases registered with Alias, using
+// ResolveAlias, before every operation. Credentials for an alias are
+// therefore read from, stored under, and forgotten from the alias's target
+// hostname.
+//
+// The returned source always uses the receiver's current credentials source,
+// so it remains valid after a call to SetCredentialsSource. Aliases are
+// resolved at the time of each operation.
+func (d *Disco) AliasedCredentialsSource() auth.CredentialsSource {
+	return aliasedCredentialsSource{disco: d}
+}
+
+type aliasedCredentialsSource struct {
+	disco *Disco
+}
+
+var _ auth.ScopedCredentialsSource = aliasedCredentialsSource{}
+var _ auth.CredentialsInvalidator = aliasedCredentialsSource{}
+var _ auth.ContextCredentialsSource = aliasedCredentialsSource{}
+
+func (s aliasedCredentialsSource) ForHost(host svchost.Hostname) (auth.HostCredentials, error) {
+	return s.disco.CredentialsSource().ForHost(s.disco.ResolveAlias(host))
+}
+
+func (s aliasedCredentialsSource) ForHostContext(ctx context.Context, host svchost.Hostname) (auth.HostCredentials, error) {
+	return auth.CredentialsForHostContext(ctx, s.disco.CredentialsSource(), s.disco.ResolveAlias(host))
+}
+
+func (s aliasedCredentialsSource) ForScope(scope auth.CredentialsScope) (auth.HostCredentials, error) {
+	scope.Host = s.disco.ResolveAlias(scope.Host)
+	return auth.CredentialsForScope(s.disco.CredentialsSource(), scope)
+}
+
+func (s aliasedCredentialsSource) StoreForHost(host svchost.Hostname, credentials auth.HostCredentialsWritable) error {
+	return s.disco.CredentialsSource().StoreForHost(s.disco.ResolveAlias(host), credentials)
+}
+
+func (s aliasedCredentialsSource) ForgetForHost(host svchost.Hostname) error {
+	return s.disco.CredentialsSource().ForgetForHost(s.disco.ResolveAlias(host))
+}
+
+func (s aliasedCredentialsSource) InvalidateForHost(host svchost.Hostname) {
+	auth.InvalidateForHost(s.disco.CredentialsSource(), s.disco.ResolveAlias(host))
+}
@@ -0,0 +1,95 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"testing"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+	"github.com/hashicorp/terraform-svchost/auth"
+)
+
+func TestDiscoAliasedCredentialsSource(t *testing.T) {
+	alias := svchost.Hostname("alias.example.com")
+	target := svchost.Hostname("target.example.com")
+
+	store := testCredentialsStore{}
+	d := New()
+	d.SetCredentialsSource(store)
+	d.Alias(alias, target)
+	src := d.AliasedCredentialsSource()
+
+	if err := src.StoreForHost(alias, auth.HostCredentialsToken("abc123")); err != nil {
+		t.Fatalf("unexpected store error: %s", err)
+	}
+	if _, stored := store[alias]; stored {
+		t.Errorf("credentials were stored under the alias hostname")
+	}
+	if got, want := store[target], auth.HostCredentialsWritable(auth.HostCredentialsToken("abc123")); got != want {
+		t.Errorf("wrong credentials stored for target %#v; want %#v", got, want)
+	}
+
+	creds, err := src.ForHost(alias)
+	if err != nil {
+		t.Fatalf("unexpected lookup error: %s", err)
+	}
+	if creds == nil || creds.Token() != "abc123" {
+		t.Errorf("wrong credentials for alias %#v", creds)
+	}
+
+	creds, err = auth.CredentialsForScope(src, auth.CredentialsScope{Host: alias, Path: "/foo"})
+	if err != nil {
+		t.Fatalf("unexpected lookup error: %s", err)
+	}
+	if creds == nil || creds.Token() != "abc123" {
+		t.Errorf("wrong credentials for alias scope %#v", creds)
+	}
+
+	if err := src.ForgetForHost(alias); err != nil {
+		t.Fatalf("unexpected forget error: %s", err)
+	}
+	if _, stored := store[target]; stored {
+		t.Errorf("credentials for target were not forgotten")
+	}
+
+	// Operations on a hostname that is not an alias use that hostname as-is.
+	other := svchost.Hostname("other.example.com")
+	if err := src.StoreForHost(other, auth.HostCredentialsToken("xyz")); err != nil {
+		t.Fatalf("unexpected store error: %s", err)
+	}
+	if _, stored := store[other]; !stored {
+		t.Errorf("credentials were not stored for non-alias hostname")
+	}
+}
+
+func TestDiscoResolveAlias(t *testing.T) {
+	d := New()
+	d.Alias("alias.example.com", "target.example.com")
+
+	if got, want := d.ResolveAlias("alias.example.com"), svchost.Hostname("target.example.com"); got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+	if got, want := d.ResolveAlias("other.example.com"), svchost.Hostname("other.example.com"); got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+}
+
+// testCredentialsStore is a writable in-memory auth.CredentialsSource.
+type testCredentialsStore map[svchost.Hostname]auth.HostCredentialsWritable
+
+func (s testCredentialsStore) ForHost(host svchost.Hostname) (auth.HostCredentials, error) {
+	if creds, ok := s[host]; ok {
+		return creds, nil
+	}
+	return nil, nil
+}
+
+func (s testCredentialsStore) StoreForHost(host svchost.Hostname, credentials auth.HostCredentialsWritable) error {
+	s[host] = credentials
+	return nil
+}
+
+func (s testCredentialsStore) ForgetForHost(host svchost.Hostname) error {
+	delete(s, host)
+	return nil
+}
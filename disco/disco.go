@@ -8,6 +8,7 @@
 package disco
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,26 +20,18 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/oauth2"
+
 	svchost "github.com/hashicorp/terraform-svchost"
 	"github.com/hashicorp/terraform-svchost/auth"
 )
 
-const (
-	// Fixed path to the discovery manifest.
-	discoPath = "/.well-known/terraform.json"
-
-	// Arbitrary-but-small number to prevent runaway redirect loops.
-	maxRedirects = 3
+// Fixed path to the discovery manifest.
+const discoPath = "/.well-known/terraform.json"
 
-	// Arbitrary-but-small time limit to prevent UI "hangs" during discovery.
-	discoTimeout = 11 * time.Second
-
-	// 1MB - to prevent abusive services from using loads of our memory.
-	maxDiscoDocBytes = 1 * 1024 * 1024
-)
-
-// httpTransport is overridden during tests, to skip TLS verification.
-var httpTransport = defaultHTTPTransport()
+// errTooManyRedirects is returned from the HTTP client's CheckRedirect
+// function when a discovery request is redirected too many times.
+var errTooManyRedirects = errors.New("too many redirects")
 
 // Disco is the main type in this package, which allows discovery on given
 // hostnames and caches the results by hostname to avoid repeated requests
@@ -47,39 +40,58 @@ type Disco struct {
 	// must lock "mu" while interacting with these maps
 	aliases   map[svchost.Hostname]svchost.Hostname
 	hostCache map[svchost.Hostname]*Host
+	inflight  map[svchost.Hostname]*discoveryCall
 	mu        sync.Mutex
 
 	credsSrc auth.CredentialsSource
 
+	// Settings from the options given to NewWithOptions. These must not
+	// change after the Disco is created.
+	timeout      time.Duration
+	maxRedirects int
+	maxDocBytes  int64
+	defaultTTL   time.Duration
+	cache        Cache
+	staleIfError time.Duration
+	retry        RetryPolicy
+	logger       *log.Logger
+	now          func() time.Time
+
 	// Transport is a custom http.RoundTripper to use.
 	Transport http.RoundTripper
 }
 
-// ErrServiceDiscoveryNetworkRequest represents the error that occurs when
-// the service discovery fails for an unknown network problem.
-type ErrServiceDiscoveryNetworkRequest struct {
-	err error
-}
-
-func (e ErrServiceDiscoveryNetworkRequest) Error() string {
-	wrappedError := fmt.Errorf("failed to request discovery document: %w", e.err)
-	return wrappedError.Error()
-}
-
 // New returns a new initialized discovery object.
 func New() *Disco {
-	return NewWithCredentialsSource(nil)
+	return NewWithOptions()
 }
 
 // NewWithCredentialsSource returns a new discovery object initialized with
 // the given credentials source.
 func NewWithCredentialsSource(credsSrc auth.CredentialsSource) *Disco {
-	return &Disco{
-		aliases:   make(map[svchost.Hostname]svchost.Hostname),
-		hostCache: make(map[svchost.Hostname]*Host),
-		credsSrc:  credsSrc,
-		Transport: httpTransport,
+	return NewWithOptions(WithCredentialsSource(credsSrc))
+}
+
+// NewWithOptions returns a new discovery object configured by the given
+// options, which are applied in order. Settings that no option overrides
+// take the same defaults as New.
+func NewWithOptions(opts ...Option) *Disco {
+	d := &Disco{
+		aliases:      make(map[svchost.Hostname]svchost.Hostname),
+		hostCache:    make(map[svchost.Hostname]*Host),
+		inflight:     make(map[svchost.Hostname]*discoveryCall),
+		timeout:      DefaultTimeout,
+		maxRedirects: DefaultMaxRedirects,
+		maxDocBytes:  DefaultMaxDocumentSize,
+		defaultTTL:   DefaultTTL,
+		logger:       log.Default(),
+		now:          time.Now,
+		Transport:    defaultHTTPTransport(),
+	}
+	for _, opt := range opts {
+		opt(d)
 	}
+	return d
 }
 
 func (d *Disco) SetUserAgent(uaString string) {
@@ -113,16 +125,143 @@ func (d *Disco) CredentialsSource() auth.CredentialsSource {
 // CredentialsForHost returns a non-nil HostCredentials if the embedded source has
 // credentials available for the host, or host alias, and a nil HostCredentials if it does not.
 func (d *Disco) CredentialsForHost(hostname svchost.Hostname) (auth.HostCredentials, error) {
+	return d.CredentialsForHostContext(context.Background(), hostname)
+}
+
+// CredentialsForHostContext is like CredentialsForHost, but passes the given
+// context to the credentials source using auth.CredentialsForHostContext.
+func (d *Disco) CredentialsForHostContext(ctx context.Context, hostname svchost.Hostname) (auth.HostCredentials, error) {
 	if d.credsSrc == nil {
 		return nil, nil
 	}
+	return auth.CredentialsForHostContext(ctx, d.credsSrc, d.ResolveAlias(hostname))
+}
+
+// CredentialsTransport returns an auth.Transport that applies credentials
+// from the receiver's credentials source to each request made through the
+// given base transport, honoring any hostname aliases registered with Alias.
+//
+// If base is nil, the receiver's Transport is used.
+func (d *Disco) CredentialsTransport(base http.RoundTripper) *auth.Transport {
+	if base == nil {
+		base = d.Transport
+	}
+	return &auth.Transport{
+		Source: d.AliasedCredentialsSource(),
+		Base:   base,
+	}
+}
+
+// ResolveAlias returns the target of the given hostname if it was registered
+// as an alias using Alias, or the given hostname otherwise.
+//
+// This must be called _without_ d.mu locked.
+func (d *Disco) ResolveAlias(hostname svchost.Hostname) svchost.Hostname {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	if aliasedHost, aliasExists := d.aliases[hostname]; aliasExists {
-		log.Printf("[DEBUG] CredentialsForHost found alias %s for %s", hostname, aliasedHost)
-		hostname = aliasedHost
+		return aliasedHost
+	}
+	return hostname
+}
+
+// OAuth2RefreshFunc returns an auth.RefreshFunc, for use with
+// auth.RefreshingCredentialsSource, that refreshes auth.HostCredentialsOAuth2
+// credentials using the token endpoint of the OAuth client that each host
+// advertises for the given service identifier, such as "login.v1".
+//
+// As with TokenExchangeEndpoint, discovery is performed without credentials
+// if the host's discovery result is not already cached, because the
+// credentials being refreshed are the ones that discovery would use.
+// Requests to the token endpoint use the receiver's Transport.
+func (d *Disco) OAuth2RefreshFunc(serviceID string) auth.RefreshFunc {
+	client := &http.Client{
+		Transport: d.Transport,
+		Timeout:   d.timeout,
+	}
+	return auth.OAuth2RefreshFunc(func(ctx context.Context, hostname svchost.Hostname) (*oauth2.Config, error) {
+		host, err := d.discoverForCredentials(ctx, hostname)
+		if err != nil {
+			return nil, err
+		}
+		oauthClient, err := host.ServiceOAuthClient(serviceID)
+		if err != nil {
+			return nil, err
+		}
+		if oauthClient.TokenURL == nil {
+			return nil, fmt.Errorf("service %s on host %s has no token endpoint", serviceID, hostname.ForDisplay())
+		}
+		return oauthClient.Config(), nil
+	}, client)
+}
+
+// TokenExchangeServiceID is the service identifier that hosts use to
+// advertise the URL of an IETF RFC 8693 token exchange endpoint.
+const TokenExchangeServiceID = "token-exchange.v1"
+
+// TokenExchangeEndpoint returns the URL of the token exchange endpoint that
+// the given host advertises with the service identifier
+// TokenExchangeServiceID, or nil if the host does not provide that service.
+// It is intended for use as auth.TokenExchangeConfig.EndpointForHost.
+//
+// If the host's discovery result is not already cached, discovery is
+// performed without credentials, because the token exchange is what will
+// produce the credentials for the host. That result is not cached.
+func (d *Disco) TokenExchangeEndpoint(hostname svchost.Hostname) (*url.URL, error) {
+	host, err := d.discoverForCredentials(context.Background(), hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := host.ServiceURL(TokenExchangeServiceID)
+	if _, notProvided := err.(*ErrServiceNotProvided); notProvided {
+		return nil, nil
 	}
-	return d.credsSrc.ForHost(hostname)
+	return u, err
+}
+
+// ClientCredentialsTokenURL returns a function, for use as
+// auth.ClientCredentialsConfig.TokenURLForHost, that returns the token
+// endpoint of the OAuth client that each host advertises for the given
+// service identifier, such as "login.v1". The function returns nil for hosts
+// that don't provide the service or whose client doesn't support
+// OAuthClientCredentialsGrant.
+//
+// As with TokenExchangeEndpoint, discovery is performed without credentials
+// if the host's discovery result is not already cached.
+func (d *Disco) ClientCredentialsTokenURL(serviceID string) func(svchost.Hostname) (*url.URL, error) {
+	return func(hostname svchost.Hostname) (*url.URL, error) {
+		host, err := d.discoverForCredentials(context.Background(), hostname)
+		if err != nil {
+			return nil, err
+		}
+		oauthClient, err := host.ServiceOAuthClient(serviceID)
+		if _, notProvided := err.(*ErrServiceNotProvided); notProvided {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !oauthClient.SupportedGrantTypes.Has(OAuthClientCredentialsGrant) {
+			return nil, nil
+		}
+		return oauthClient.TokenURL, nil
+	}
+}
+
+// discoverForCredentials returns the cached discovery result for the given
+// hostname if there is one that has not expired, or otherwise performs
+// discovery without credentials and without caching the result. It is for
+// use by credentials sources that obtain the credentials for a host from the
+// host itself, which would otherwise be asked for credentials in order to
+// perform discovery.
+func (d *Disco) discoverForCredentials(ctx context.Context, hostname svchost.Hostname) (*Host, error) {
+	d.mu.Lock()
+	host, cached := d.hostCache[hostname]
+	d.mu.Unlock()
+	if cached && host.fresh(d.now()) {
+		return host, nil
+	}
+	return d.discover(ctx, hostname, false, host)
 }
 
 // ForceHostServices provides a pre-defined set of services for a given
@@ -150,14 +289,18 @@ func (d *Disco) ForceHostServices(hostname svchost.Hostname, services map[string
 		hostname:  hostname.ForDisplay(),
 		services:  services,
 		transport: d.Transport,
+		logger:    d.logger,
+		fetchedAt: d.now(),
 	}
+	// Don't let a discovery already in progress replace these services.
+	delete(d.inflight, hostname)
 	d.mu.Unlock()
 }
 
 // Alias accepts an alias and target Hostname. When service discovery is performed
 // or credentials are requested for the alias hostname, the target will be consulted instead.
 func (d *Disco) Alias(alias, target svchost.Hostname) {
-	log.Printf("[DEBUG] Service discovery for %s aliased as %s", target, alias)
+	d.logger.Printf("[DEBUG] Service discovery for %s aliased as %s", target, alias)
 	d.mu.Lock()
 	d.aliases[alias] = target
 	d.mu.Unlock()
@@ -167,6 +310,12 @@ func (d *Disco) Alias(alias, target svchost.Hostname) {
 // already have been validated and prepared with svchost.ForComparison) and
 // returns an object describing the services available at that host.
 //
+// Results are cached for as long as the discovery response's HTTP caching
+// headers allow, or for the Disco's default TTL if there are none. Once a
+// result expires, the next call revalidates it with a conditional request if
+// the response included an ETag or Last-Modified header, or fetches it again
+// otherwise.
+//
 // If a given hostname supports no Terraform services at all, a non-nil but
 // empty Host object is returned. When giving feedback to the end user about
 // such situations, we say "host <name> does not provide a <service> service",
@@ -174,56 +323,211 @@ func (d *Disco) Alias(alias, target svchost.Hostname) {
 // or due to the host not providing Terraform services at all, since we don't
 // wish to expose the detail of whole-host discovery to an end-user.
 func (d *Disco) Discover(hostname svchost.Hostname) (*Host, error) {
-	// In this method we use d.mu locking only to avoid corrupting d.hostCache
-	// by concurrent writes, and not to prevent concurrent discovery requests.
-	// If two clients concurrently request the same hostname then we could
-	// potentially send two concurrent discovery requests over the network,
-	// in which case it's unspecified which one will "win" and end up being
-	// stored in the cache for future requests. In practice this shouldn't
-	// matter because we're already assuming (by caching the results at all)
-	// that a host will generally not vary its results in meaningful ways
-	// between requests made in close time proximity.
+	return d.DiscoverContext(context.Background(), hostname)
+}
+
+// DiscoverContext is like Discover, but the discovery request, including
+// obtaining any credentials for it, is aborted if the given context is
+// cancelled or its deadline passes. In that case the context's error is
+// returned.
+//
+// Concurrent calls for the same hostname share a single discovery request
+// and all receive its result. Cancelling the context of one caller returns
+// early for that caller only; the shared request is aborted only once every
+// caller waiting for it has given up.
+func (d *Disco) DiscoverContext(ctx context.Context, hostname svchost.Hostname) (*Host, error) {
 	d.mu.Lock()
-	if host, cached := d.hostCache[hostname]; cached {
+	host, cached := d.hostCache[hostname]
+	if cached && host.fresh(d.now()) {
 		d.mu.Unlock()
 		return host, nil
 	}
+	call, ok := d.inflight[hostname]
+	if !ok {
+		call = d.startDiscovery(ctx, hostname, host)
+	}
+	call.waiters++
+	if deadline, ok := ctx.Deadline(); !ok {
+		call.unbounded = true
+	} else if deadline.After(call.deadline) {
+		call.deadline = deadline
+	}
 	d.mu.Unlock()
 
-	host, err := d.discover(hostname)
-	if err != nil {
-		return nil, err
+	select {
+	case <-call.done:
+		return call.host, call.err
+	case <-ctx.Done():
+		d.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody wants the result anymore, so we'll abort the request.
+			// A later call for this hostname will start a new one.
+			call.cancel()
+			if d.inflight[hostname] == call {
+				delete(d.inflight, hostname)
+			}
+		}
+		d.mu.Unlock()
+		return nil, ctx.Err()
 	}
-	d.mu.Lock()
-	d.hostCache[hostname] = host
-	d.mu.Unlock()
+}
 
-	return host, nil
+// discoveryCall represents a discovery request that is in progress on behalf
+// of one or more callers of DiscoverContext.
+type discoveryCall struct {
+	// done is closed once host and err are set.
+	done chan struct{}
+	host *Host
+	err  error
+
+	// waiters is the number of callers waiting for the result, and cancel
+	// aborts the request. deadline is the latest context deadline of the
+	// callers that have waited for the result, unless unbounded is true
+	// because at least one of them had no deadline. d.mu must be locked to
+	// access waiters, deadline and unbounded.
+	waiters   int
+	deadline  time.Time
+	unbounded bool
+	cancel    context.CancelFunc
+}
+
+// callDeadlineKey is the context key under which startDiscovery records the
+// function that reports the deadline of a shared discovery request, which
+// has no deadline of its own because each caller waiting for it might have
+// a different one.
+type callDeadlineKey struct{}
+
+// requestDeadline returns the deadline of the given context, if it has one,
+// or the deadline of the shared discovery request it was created for.
+func requestDeadline(ctx context.Context) (time.Time, bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline, true
+	}
+	if f, ok := ctx.Value(callDeadlineKey{}).(func() (time.Time, bool)); ok {
+		return f()
+	}
+	return time.Time{}, false
+}
+
+// startDiscovery starts discovery for the given hostname in the background,
+// registers it in d.inflight, and returns it. If stale is not nil, it is the
+// expired cached result to revalidate. The new result is cached unless the
+// request fails, the response forbids caching, or the host is forgotten
+// before it completes.
+//
+// The request uses a context that carries the values of the given context
+// but not its cancellation, because the caller that started the request may
+// not be the last one waiting for it.
+//
+// This must be called with d.mu locked.
+func (d *Disco) startDiscovery(ctx context.Context, hostname svchost.Hostname, stale *Host) *discoveryCall {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	call := &discoveryCall{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	d.inflight[hostname] = call
+	ctx = context.WithValue(ctx, callDeadlineKey{}, func() (time.Time, bool) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return call.deadline, !call.unbounded
+	})
+
+	go func() {
+		defer cancel()
+		host, fromNetwork, err := d.discoverCached(ctx, hostname, stale)
+
+		d.mu.Lock()
+		current := d.inflight[hostname] == call
+		if current {
+			delete(d.inflight, hostname)
+			switch {
+			case err != nil:
+				// Keep any stale entry, so it can be revalidated next time.
+			case host.noStore:
+				delete(d.hostCache, hostname)
+			default:
+				d.hostCache[hostname] = host
+			}
+		}
+		call.host, call.err = host, err
+		d.mu.Unlock()
+
+		if current && err == nil && fromNetwork {
+			d.storeCached(hostname, host)
+		}
+		close(call.done)
+	}()
+
+	return call
 }
 
 // DiscoverServiceURL is a convenience wrapper for discovery on a given
 // hostname and then looking up a particular service in the result.
 func (d *Disco) DiscoverServiceURL(hostname svchost.Hostname, serviceID string) (*url.URL, error) {
-	host, err := d.Discover(hostname)
+	return d.DiscoverServiceURLContext(context.Background(), hostname, serviceID)
+}
+
+// DiscoverServiceURLContext is like DiscoverServiceURL, but performs
+// discovery using DiscoverContext with the given context.
+func (d *Disco) DiscoverServiceURLContext(ctx context.Context, hostname svchost.Hostname, serviceID string) (*url.URL, error) {
+	host, err := d.discoverForCredentials(ctx, hostname)
 	if err != nil {
 		return nil, err
 	}
 	return host.ServiceURL(serviceID)
 }
 
-// discover implements the actual discovery process, with its result cached
-// by the public-facing Discover method.
+// discoverCached is like discover with credentials, but first consults the
+// receiver's persistent cache if there is no stale in-memory result, and
+// falls back on the stale result, if allowed by WithStaleIfError, if the
+// discovery request fails due to a network problem. fromNetwork is true if
+// the returned host came from a discovery request, and so should be written
+// to the persistent cache.
 //
-// This must be called _without_ d.mu locked. d.mu is there only to protect
-// the integrity of our internal maps, and not to prevent multiple concurrent
-// service discovery lookups even for the same hostname.
-func (d *Disco) discover(hostname svchost.Hostname) (*Host, error) {
-	d.mu.Lock()
-	if aliasedHost, aliasExists := d.aliases[hostname]; aliasExists {
-		log.Printf("[DEBUG] Discover found alias %s for %s", hostname, aliasedHost)
-		hostname = aliasedHost
+// This must be called _without_ d.mu locked.
+func (d *Disco) discoverCached(ctx context.Context, hostname svchost.Hostname, stale *Host) (host *Host, fromNetwork bool, err error) {
+	if stale == nil {
+		stale = d.loadCached(hostname)
+		if stale != nil && stale.fresh(d.now()) {
+			return stale, false, nil
+		}
+	}
+
+	host, err = d.discover(ctx, hostname, true, stale)
+	if err == nil {
+		return host, true, nil
+	}
+
+	var netErr ErrServiceDiscoveryNetworkRequest
+	if stale != nil && d.staleIfError > 0 && errors.As(err, &netErr) {
+		if d.now().Before(stale.expiresAt.Add(d.staleIfError)) {
+			d.logger.Printf("[WARN] Using stale discovery result for %s from %s: %s", hostname, stale.fetchedAt, err)
+			return stale, false, nil
+		}
+	}
+	return nil, false, err
+}
+
+// discover implements the actual discovery process, with its result cached,
+// and concurrent requests for the same hostname combined, by the
+// public-facing DiscoverContext method. If withCredentials is true, the
+// discovery request includes any credentials available for the host.
+//
+// If stale is not nil, it is an expired result for the same host. The request
+// is then made conditional on that result's validators, if it has any, and
+// if the server confirms that the result has not changed then a copy of it
+// with updated expiry information is returned.
+//
+// This must be called _without_ d.mu locked.
+func (d *Disco) discover(ctx context.Context, hostname svchost.Hostname, withCredentials bool, stale *Host) (*Host, error) {
+	// ResolveAlias is called for every credentialed request, so we log
+	// aliases here, once per discovery, rather than there.
+	if target := d.ResolveAlias(hostname); target != hostname {
+		d.logger.Printf("[DEBUG] Discover found alias %s for %s", hostname, target)
+		hostname = target
 	}
-	d.mu.Unlock()
 
 	discoURL := &url.URL{
 		Scheme: "https",
@@ -233,48 +537,79 @@ func (d *Disco) discover(hostname svchost.Hostname) (*Host, error) {
 
 	client := &http.Client{
 		Transport: d.Transport,
-		Timeout:   discoTimeout,
+		Timeout:   d.timeout,
 
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			log.Printf("[DEBUG] Service discovery redirected to %s", req.URL)
-			if len(via) > maxRedirects {
-				return errors.New("too many redirects") // this error will never actually be seen
+			d.logger.Printf("[DEBUG] Service discovery redirected to %s", req.URL)
+			if len(via) > d.maxRedirects {
+				return errTooManyRedirects // this error will never actually be seen
 			}
 			return nil
 		},
 	}
 
-	req := &http.Request{
-		Header: make(http.Header),
-		Method: "GET",
-		URL:    discoURL,
+	req, err := http.NewRequestWithContext(ctx, "GET", discoURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
-
-	creds, err := d.CredentialsForHost(hostname)
-	if err != nil {
-		log.Printf("[WARN] Failed to get credentials for %s: %s (ignoring)", hostname, err)
+	if stale != nil {
+		if stale.etag != "" {
+			req.Header.Set("If-None-Match", stale.etag)
+		}
+		if stale.lastModified != "" {
+			req.Header.Set("If-Modified-Since", stale.lastModified)
+		}
 	}
-	if creds != nil {
-		// Update the request to include credentials.
-		creds.PrepareRequest(req)
+
+	hadCredentials := false
+	if withCredentials {
+		creds, err := d.CredentialsForHostContext(ctx, hostname)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			d.logger.Printf("[WARN] Failed to get credentials for %s: %s (ignoring)", hostname, err)
+		}
+		if creds != nil {
+			// Update the request to include credentials.
+			creds.PrepareRequest(req)
+			hadCredentials = true
+		}
 	}
 
-	log.Printf("[DEBUG] Service discovery for %s at %s", hostname, discoURL)
+	d.logger.Printf("[DEBUG] Service discovery for %s at %s", hostname, discoURL)
 
-	resp, err := client.Do(req)
+	resp, err := d.doWithRetry(ctx, client, req, hostname)
 	if err != nil {
-		return nil, ErrServiceDiscoveryNetworkRequest{err}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// The caller gave up on discovery, so this isn't a network problem.
+			return nil, ctxErr
+		}
+		return nil, ErrServiceDiscoveryNetworkRequest{Hostname: hostname, URL: discoURL, Err: err}
 	}
 	defer resp.Body.Close()
+	finalURL := resp.Request.URL
+
+	fetchedAt := d.now()
+	policy := responseCachePolicy(resp.Header, fetchedAt, d.defaultTTL)
+
+	if resp.StatusCode == http.StatusNotModified && stale != nil && stale.hasValidators() {
+		d.logger.Printf("[DEBUG] Service discovery document for %s has not changed", hostname)
+		host := *stale
+		host.setCachePolicy(policy, fetchedAt)
+		return &host, nil
+	}
 
 	host := &Host{
 		// Use the discovery URL from resp.Request in
 		// case the client followed any redirects.
-		discoURL:  resp.Request.URL,
+		discoURL:  finalURL,
 		hostname:  hostname.ForDisplay(),
 		transport: d.Transport,
+		logger:    d.logger,
 	}
+	host.setCachePolicy(policy, fetchedAt)
 
 	// Return the host without any services.
 	if resp.StatusCode == 404 {
@@ -282,53 +617,93 @@ func (d *Disco) discover(hostname svchost.Hostname) (*Host, error) {
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to request discovery document: %s", resp.Status)
+		statusErr := ErrServiceDiscoveryStatus{
+			Hostname:   hostname,
+			URL:        finalURL,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Snippet:    readSnippet(resp.Body),
+		}
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return nil, &ErrServiceDiscoveryUnauthorized{
+				ErrServiceDiscoveryStatus: statusErr,
+				HadCredentials:            hadCredentials,
+			}
+		}
+		return nil, &statusErr
 	}
 
 	contentType := resp.Header.Get("Content-Type")
 	mediaType, _, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		return nil, fmt.Errorf("discovery URL has a malformed Content-Type %q", contentType)
+	if err != nil || mediaType != "application/json" {
+		if err == nil {
+			contentType = mediaType
+		}
+		return nil, &ErrServiceDiscoveryContentType{
+			Hostname:    hostname,
+			URL:         finalURL,
+			StatusCode:  resp.StatusCode,
+			ContentType: contentType,
+			Snippet:     readSnippet(resp.Body),
+			Err:         err,
+		}
 	}
-	if mediaType != "application/json" {
-		return nil, fmt.Errorf("discovery URL returned an unsupported Content-Type %q", mediaType)
+
+	tooLarge := &ErrServiceDiscoveryTooLarge{
+		Hostname:   hostname,
+		URL:        finalURL,
+		StatusCode: resp.StatusCode,
+		Size:       resp.ContentLength,
+		Limit:      d.maxDocBytes,
 	}
 
 	// This doesn't catch chunked encoding, because ContentLength is -1 in that case.
-	if resp.ContentLength > maxDiscoDocBytes {
+	if resp.ContentLength > d.maxDocBytes {
 		// Size limit here is not a contractual requirement and so we may
 		// adjust it over time if we find a different limit is warranted.
-		return nil, fmt.Errorf(
-			"discovery doc response is too large (got %d bytes; limit %d)",
-			resp.ContentLength, maxDiscoDocBytes,
-		)
+		return nil, tooLarge
 	}
 
 	// If the response is using chunked encoding then we can't predict its
 	// size, but we'll at least prevent reading the entire thing into memory.
-	lr := io.LimitReader(resp.Body, maxDiscoDocBytes)
+	// We read one byte more than the limit so we can tell if it was exceeded.
+	lr := io.LimitReader(resp.Body, d.maxDocBytes+1)
 
 	servicesBytes, err := io.ReadAll(lr)
 	if err != nil {
-		return nil, fmt.Errorf("error reading discovery document body: %v", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, ErrServiceDiscoveryNetworkRequest{Hostname: hostname, URL: finalURL, Err: err}
+	}
+	if int64(len(servicesBytes)) > d.maxDocBytes {
+		return nil, tooLarge
 	}
 
 	var services map[string]interface{}
 	err = json.Unmarshal(servicesBytes, &services)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode discovery document as a JSON object: %v", err)
+		return nil, &ErrServiceDiscoveryMalformed{
+			Hostname:   hostname,
+			URL:        finalURL,
+			StatusCode: resp.StatusCode,
+			Snippet:    responseSnippet(servicesBytes),
+			Err:        err,
+		}
 	}
 	host.services = services
 
 	return host, nil
 }
 
-// Forget invalidates any cached record of the given hostname. If the host
-// has no cache entry then this is a no-op.
+// Forget invalidates any cached record of the given hostname, including any
+// entry in the persistent cache set with WithCache. If the host has no cache
+// entry then this is a no-op.
 func (d *Disco) Forget(hostname svchost.Hostname) {
 	d.mu.Lock()
 	d.forgetInternal(hostname)
 	d.mu.Unlock()
+	d.forgetCached(hostname)
 }
 
 // forgetInternal is the main implementation of Forget that assumes the
@@ -336,13 +711,24 @@ func (d *Disco) Forget(hostname svchost.Hostname) {
 // places like ForgetAlias.
 func (d *Disco) forgetInternal(hostname svchost.Hostname) {
 	delete(d.hostCache, hostname)
+
+	// Any discovery already in progress will still deliver its result to
+	// the callers waiting for it, but that result won't be cached.
+	delete(d.inflight, hostname)
 }
 
-// ForgetAll is like Forget, but for all of the hostnames that have cache entries.
+// ForgetAll is like Forget, but for all of the hostnames that have in-memory
+// cache entries.
 func (d *Disco) ForgetAll() {
 	d.mu.Lock()
+	hostnames := make([]svchost.Hostname, 0, len(d.hostCache))
+	for hostname := range d.hostCache {
+		hostnames = append(hostnames, hostname)
+	}
 	d.hostCache = make(map[svchost.Hostname]*Host)
+	d.inflight = make(map[svchost.Hostname]*discoveryCall)
 	d.mu.Unlock()
+	d.forgetCached(hostnames...)
 }
 
 // ForgetAlias removes a previously aliased hostname as well as its cached entry, if any exist.
@@ -352,4 +738,5 @@ func (d *Disco) ForgetAlias(alias svchost.Hostname) {
 	delete(d.aliases, alias)
 	d.forgetInternal(alias)
 	d.mu.Unlock()
+	d.forgetCached(alias)
 }
@@ -3,12 +3,17 @@
 package disco
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,17 +21,6 @@ import (
 	"github.com/hashicorp/terraform-svchost/auth"
 )
 
-func TestMain(m *testing.M) {
-	// During all tests we override the HTTP transport we use for discovery
-	// so it'll tolerate the locally-generated TLS certificates we use
-	// for test URLs.
-	httpTransport = &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-
-	os.Exit(m.Run())
-}
-
 func TestDiscover(t *testing.T) {
 	t.Run("happy path", func(t *testing.T) {
 		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
@@ -48,7 +42,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		discovered, err := d.Discover(host)
 		if err != nil {
 			t.Fatalf("unexpected discovery error: %s", err)
@@ -90,7 +84,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		discovered, err := d.Discover(host)
 		if err != nil {
 			t.Fatalf("unexpected discovery error: %s", err)
@@ -124,7 +118,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		d.SetCredentialsSource(auth.StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
 			host: {
 				"token": "abc123",
@@ -141,7 +135,7 @@ func TestDiscover(t *testing.T) {
 			"wotsit.v2": "/foo",
 		}
 
-		d := New()
+		d := testDisco()
 		d.ForceHostServices(svchost.Hostname("example.com"), forced)
 
 		givenHost := "example.com"
@@ -193,7 +187,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		discovered, err := d.Discover(host)
 		if err == nil {
 			t.Fatalf("expected a discovery error")
@@ -218,7 +212,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		discovered, err := d.Discover(host)
 		if err == nil {
 			t.Fatalf("expected a discovery error")
@@ -247,7 +241,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		discovered, err := d.Discover(host)
 		if err != nil {
 			t.Fatalf("unexpected discovery error: %s", err)
@@ -269,7 +263,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		discovered, err := d.Discover(host)
 
 		if err != nil {
@@ -296,7 +290,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 
 		transport := d.Transport.(*http.Transport)
 
@@ -316,6 +310,38 @@ func TestDiscover(t *testing.T) {
 			t.Errorf("discovered not nil (empty); should be")
 		}
 	})
+	t.Run("context cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		donec := make(chan bool, 1)
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			// Give up on discovery once the request has reached the server.
+			cancel()
+			<-donec
+		})
+		defer cleanup()
+		defer func() { donec <- true }()
+
+		givenHost := "localhost" + portStr
+		host, err := svchost.ForComparison(givenHost)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco()
+		discovered, err := d.DiscoverContext(ctx, host)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("wrong error %v; want context.Canceled", err)
+		}
+		if discovered != nil {
+			t.Errorf("discovered not nil (empty); should be")
+		}
+
+		// A failed discovery must not be cached, so a later call with a
+		// live context tries again.
+		if _, ok := d.hostCache[host]; ok {
+			t.Errorf("cancelled discovery result was cached")
+		}
+	})
 	t.Run("redirect", func(t *testing.T) {
 		// For this test, we have two servers and one redirects to the other
 		portStr1, close1 := testServer(func(w http.ResponseWriter, r *http.Request) {
@@ -338,7 +364,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("test server hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		discovered, err := d.Discover(host)
 		if err != nil {
 			t.Fatalf("unexpected discovery error: %s", err)
@@ -388,7 +414,7 @@ func TestDiscover(t *testing.T) {
 			t.Fatalf("alias hostname is invalid: %s", err)
 		}
 
-		d := New()
+		d := testDisco()
 		d.SetCredentialsSource(auth.StaticCredentialsSource(map[svchost.Hostname]map[string]any{
 			target: {
 				"token": "hunter2",
@@ -433,6 +459,742 @@ func TestDiscover(t *testing.T) {
 	})
 }
 
+func TestDiscoverConcurrent(t *testing.T) {
+	t.Run("shared request", func(t *testing.T) {
+		var requests atomic.Int32
+		release := make(chan struct{})
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+			resp := []byte(`{"thingy.v1": "http://example.com/foo"}`)
+			w.Header().Add("Content-Type", "application/json")
+			w.Write(resp)
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco()
+		const callers = 10
+		results := make(chan *Host, callers)
+		errs := make(chan error, callers)
+		for i := 0; i < callers; i++ {
+			go func() {
+				discovered, err := d.Discover(host)
+				results <- discovered
+				errs <- err
+			}()
+		}
+		testWaitForWaiters(t, d, host, callers)
+		close(release)
+
+		var first *Host
+		for i := 0; i < callers; i++ {
+			if err := <-errs; err != nil {
+				t.Fatalf("unexpected discovery error: %s", err)
+			}
+			discovered := <-results
+			if first == nil {
+				first = discovered
+			}
+			if discovered != first {
+				t.Errorf("callers received different Host objects")
+			}
+		}
+		if got, want := requests.Load(), int32(1); got != want {
+			t.Errorf("wrong number of discovery requests %d; want %d", got, want)
+		}
+	})
+	t.Run("one caller cancels", func(t *testing.T) {
+		release := make(chan struct{})
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			resp := []byte(`{"thingy.v1": "http://example.com/foo"}`)
+			w.Header().Add("Content-Type", "application/json")
+			w.Write(resp)
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelledErr := make(chan error, 1)
+		go func() {
+			_, err := d.DiscoverContext(ctx, host)
+			cancelledErr <- err
+		}()
+		otherErr := make(chan error, 1)
+		go func() {
+			_, err := d.Discover(host)
+			otherErr <- err
+		}()
+		testWaitForWaiters(t, d, host, 2)
+
+		cancel()
+		if err := <-cancelledErr; !errors.Is(err, context.Canceled) {
+			t.Errorf("wrong error %v; want context.Canceled", err)
+		}
+		close(release)
+		if err := <-otherErr; err != nil {
+			t.Errorf("unexpected discovery error: %s", err)
+		}
+	})
+	t.Run("all callers cancel", func(t *testing.T) {
+		arrived := make(chan struct{})
+		aborted := make(chan struct{})
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			close(arrived)
+			<-r.Context().Done()
+			close(aborted)
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco()
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := d.DiscoverContext(ctx, host)
+				errs <- err
+			}()
+		}
+		testWaitForWaiters(t, d, host, 2)
+		<-arrived
+
+		cancel()
+		for i := 0; i < 2; i++ {
+			if err := <-errs; !errors.Is(err, context.Canceled) {
+				t.Errorf("wrong error %v; want context.Canceled", err)
+			}
+		}
+		select {
+		case <-aborted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("discovery request was not aborted")
+		}
+	})
+}
+
+func TestDiscoverCaching(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("revalidation", func(t *testing.T) {
+		var requests, notModified int
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"thingy.v1": "http://example.com/foo"}`))
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco(WithClock(clock))
+		discovered, err := d.Discover(host)
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if got, want := discovered.FetchedAt(), now; !got.Equal(want) {
+			t.Errorf("wrong fetch time %s; want %s", got, want)
+		}
+		if got, want := discovered.ExpiresAt(), now.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("wrong expiry time %s; want %s", got, want)
+		}
+
+		// Still fresh, so no request is made.
+		now = now.Add(30 * time.Second)
+		if _, err := d.Discover(host); err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if requests != 1 {
+			t.Errorf("wrong number of requests %d; want 1", requests)
+		}
+
+		// Expired, so the result is revalidated.
+		now = now.Add(time.Minute)
+		discovered, err = d.Discover(host)
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if requests != 2 || notModified != 1 {
+			t.Errorf("wrong requests %d and revalidations %d; want 2 and 1", requests, notModified)
+		}
+		if got, want := discovered.FetchedAt(), now; !got.Equal(want) {
+			t.Errorf("wrong fetch time %s; want %s", got, want)
+		}
+		if got, want := discovered.ExpiresAt(), now.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("wrong expiry time %s; want %s", got, want)
+		}
+		gotURL, err := discovered.ServiceURL("thingy.v1")
+		if err != nil {
+			t.Fatalf("unexpected service URL error: %s", err)
+		}
+		if got, want := gotURL.String(), "http://example.com/foo"; got != want {
+			t.Errorf("wrong result %q; want %q", got, want)
+		}
+	})
+	t.Run("default TTL", func(t *testing.T) {
+		var requests int
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+				t.Errorf("unexpected conditional request")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco(WithClock(clock), WithDefaultTTL(time.Minute))
+		discovered, err := d.Discover(host)
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if got, want := discovered.ExpiresAt(), now.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("wrong expiry time %s; want %s", got, want)
+		}
+
+		now = now.Add(2 * time.Minute)
+		if _, err := d.Discover(host); err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if requests != 2 {
+			t.Errorf("wrong number of requests %d; want 2", requests)
+		}
+	})
+	t.Run("no-store", func(t *testing.T) {
+		var requests int
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco(WithClock(clock))
+		for i := 0; i < 2; i++ {
+			if _, err := d.Discover(host); err != nil {
+				t.Fatalf("unexpected discovery error: %s", err)
+			}
+		}
+		if requests != 2 {
+			t.Errorf("wrong number of requests %d; want 2", requests)
+		}
+	})
+	t.Run("forced services never expire", func(t *testing.T) {
+		d := testDisco(WithClock(clock))
+		d.ForceHostServices("example.com", nil)
+
+		discovered, err := d.Discover("example.com")
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if got := discovered.ExpiresAt(); !got.IsZero() {
+			t.Errorf("wrong expiry time %s; want zero", got)
+		}
+		if got, want := discovered.FetchedAt(), now; !got.Equal(want) {
+			t.Errorf("wrong fetch time %s; want %s", got, want)
+		}
+	})
+}
+
+func TestDiscoverPersistentCache(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	var requests int
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/thingy/"}`))
+	})
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	cache := NewFileCache(t.TempDir())
+	wantURL := "https://localhost" + portStr + "/thingy/"
+
+	checkThingy := func(t *testing.T, discovered *Host) {
+		t.Helper()
+		gotURL, err := discovered.ServiceURL("thingy.v1")
+		if err != nil {
+			t.Fatalf("unexpected service URL error: %s", err)
+		}
+		if got := gotURL.String(); got != wantURL {
+			t.Errorf("wrong result %q; want %q", got, wantURL)
+		}
+	}
+
+	// The first discovery object populates the cache from the network.
+	if _, err := testDisco(WithClock(clock), WithCache(cache)).Discover(host); err != nil {
+		t.Fatalf("unexpected discovery error: %s", err)
+	}
+	if requests != 1 {
+		t.Fatalf("wrong number of requests %d; want 1", requests)
+	}
+	cleanup()
+
+	t.Run("fresh entry", func(t *testing.T) {
+		discovered, err := testDisco(WithClock(clock), WithCache(cache)).Discover(host)
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		checkThingy(t, discovered)
+		if got, want := discovered.ExpiresAt(), now.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("wrong expiry time %s; want %s", got, want)
+		}
+	})
+	t.Run("in-memory only by default", func(t *testing.T) {
+		if _, err := testDisco(WithClock(clock)).Discover(host); err == nil {
+			t.Error("discovery succeeded with the server down; want error")
+		}
+	})
+
+	now = now.Add(2 * time.Minute)
+	t.Run("stale entry", func(t *testing.T) {
+		if _, err := testDisco(WithClock(clock), WithCache(cache)).Discover(host); err == nil {
+			t.Error("discovery succeeded with the server down; want error")
+		}
+	})
+	t.Run("stale if error", func(t *testing.T) {
+		d := testDisco(WithClock(clock), WithCache(cache), WithStaleIfError(time.Hour))
+		discovered, err := d.Discover(host)
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		checkThingy(t, discovered)
+
+		now = now.Add(2 * time.Hour)
+		if _, err := d.Discover(host); err == nil {
+			t.Error("discovery succeeded with a too-stale entry; want error")
+		}
+	})
+	t.Run("forget", func(t *testing.T) {
+		testDisco(WithCache(cache)).Forget(host)
+		if entry, err := cache.Get(host); err != nil || entry != nil {
+			t.Errorf("entry still present after Forget: %#v, %v", entry, err)
+		}
+	})
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	respond := func(status int, contentType, body string) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}
+	}
+	discover := func(t *testing.T, d *Disco, h func(w http.ResponseWriter, r *http.Request)) (svchost.Hostname, error) {
+		t.Helper()
+		portStr, cleanup := testServer(h)
+		t.Cleanup(cleanup)
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+		_, err = d.Discover(host)
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		return host, err
+	}
+
+	t.Run("status", func(t *testing.T) {
+		host, err := discover(t, testDisco(), respond(500, "text/plain", "oh no"))
+		var statusErr *ErrServiceDiscoveryStatus
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("wrong error type %T", err)
+		}
+		if statusErr.Hostname != host || statusErr.StatusCode != 500 || statusErr.Snippet != "oh no" {
+			t.Errorf("wrong error details %#v", statusErr)
+		}
+		if got, want := statusErr.URL.String(), "https://"+string(host)+discoPath; got != want {
+			t.Errorf("wrong URL %q; want %q", got, want)
+		}
+		var authErr *ErrServiceDiscoveryUnauthorized
+		if errors.As(err, &authErr) {
+			t.Errorf("server error reported as an authorization failure")
+		}
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		portStr, cleanup := testServer(respond(401, "", ""))
+		defer cleanup()
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+		d := testDisco(WithCredentialsSource(auth.StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+			host: {"token": "expired"},
+		})))
+		_, err = d.Discover(host)
+		var authErr *ErrServiceDiscoveryUnauthorized
+		if !errors.As(err, &authErr) {
+			t.Fatalf("wrong error type %T", err)
+		}
+		if authErr.StatusCode != 401 || !authErr.HadCredentials {
+			t.Errorf("wrong error details %#v", authErr)
+		}
+		var statusErr *ErrServiceDiscoveryStatus
+		if !errors.As(err, &statusErr) {
+			t.Errorf("authorization failure does not wrap a status error")
+		}
+
+		_, err = discover(t, testDisco(), respond(403, "", ""))
+		if !errors.As(err, &authErr) || authErr.HadCredentials {
+			t.Errorf("wrong error %#v; want authorization failure without credentials", err)
+		}
+	})
+	t.Run("content type", func(t *testing.T) {
+		_, err := discover(t, testDisco(), respond(200, "text/html", "<html>"))
+		var ctErr *ErrServiceDiscoveryContentType
+		if !errors.As(err, &ctErr) {
+			t.Fatalf("wrong error type %T", err)
+		}
+		if ctErr.ContentType != "text/html" || ctErr.Snippet != "<html>" || ctErr.Err != nil {
+			t.Errorf("wrong error details %#v", ctErr)
+		}
+	})
+	t.Run("too large", func(t *testing.T) {
+		// The handler writes without a Content-Length, using chunked encoding.
+		_, err := discover(t, testDisco(WithMaxDocumentSize(10)), func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"thingy.v1": `))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(`"http://example.com/"}`))
+		})
+		var sizeErr *ErrServiceDiscoveryTooLarge
+		if !errors.As(err, &sizeErr) {
+			t.Fatalf("wrong error type %T", err)
+		}
+		if sizeErr.Size != -1 || sizeErr.Limit != 10 {
+			t.Errorf("wrong error details %#v", sizeErr)
+		}
+	})
+	t.Run("malformed", func(t *testing.T) {
+		_, err := discover(t, testDisco(), respond(200, "application/json", `{"thingy.v1": `))
+		var malformedErr *ErrServiceDiscoveryMalformed
+		if !errors.As(err, &malformedErr) {
+			t.Fatalf("wrong error type %T", err)
+		}
+		if malformedErr.Snippet != `{"thingy.v1": ` {
+			t.Errorf("wrong snippet %q", malformedErr.Snippet)
+		}
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("error does not wrap the JSON syntax error: %#v", malformedErr.Err)
+		}
+	})
+	t.Run("TLS", func(t *testing.T) {
+		// The default transport doesn't trust the test server's certificate.
+		_, err := discover(t, New(), respond(200, "application/json", `{}`))
+		var netErr ErrServiceDiscoveryNetworkRequest
+		if !errors.As(err, &netErr) {
+			t.Fatalf("wrong error type %T", err)
+		}
+		var tlsErr *tls.CertificateVerificationError
+		if !errors.As(err, &tlsErr) {
+			t.Errorf("error does not wrap the certificate verification error: %s", err)
+		}
+	})
+}
+
+func TestResponseSnippet(t *testing.T) {
+	long := strings.Repeat("a", maxSnippetBytes-1) + "é and more"
+	got := responseSnippet([]byte(long))
+	if want := strings.Repeat("a", maxSnippetBytes-1) + "\uFFFD"; got != want {
+		t.Errorf("wrong snippet %q; want %q", got, want)
+	}
+}
+
+func TestDiscoCredentialsTransport(t *testing.T) {
+	var authHeaderText string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaderText = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	host, err := svchost.ForComparison(serverURL.Host)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	target := svchost.Hostname("target.example.com")
+
+	d := testDisco()
+	d.SetCredentialsSource(auth.StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+		target: {
+			"token": "abc123",
+		},
+	}))
+	d.Alias(host, target)
+
+	client := &http.Client{Transport: d.CredentialsTransport(nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected request error: %s", err)
+	}
+	resp.Body.Close()
+
+	if got, want := authHeaderText, "Bearer abc123"; got != want {
+		t.Fatalf("wrong Authorization header\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestDiscoOAuth2RefreshFunc(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			w.WriteHeader(404)
+			return
+		}
+		if got, want := r.FormValue("refresh_token"), "old-refresh"; got != want {
+			t.Errorf("wrong refresh token %q; want %q", got, want)
+		}
+		if got, want := r.FormValue("client_id"), "terraform-cli"; got != want {
+			t.Errorf("wrong client ID %q; want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	host := svchost.Hostname("example.com")
+	d := testDisco()
+	d.ForceHostServices(host, map[string]interface{}{
+		"login.v1": map[string]interface{}{
+			"client":      "terraform-cli",
+			"grant_types": []interface{}{"authz_code"},
+			"authz":       server.URL + "/oauth/authorization",
+			"token":       server.URL + "/oauth/token",
+		},
+	})
+
+	refresh := d.OAuth2RefreshFunc("login.v1")
+	refreshed, err := refresh(context.Background(), host, auth.HostCredentialsOAuth2{
+		AccessToken:  "old-access",
+		RefreshToken: "old-refresh",
+		Expiry:       time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected refresh error: %s", err)
+	}
+
+	oc, ok := refreshed.(auth.HostCredentialsOAuth2)
+	if !ok {
+		t.Fatalf("wrong type of credentials %T", refreshed)
+	}
+	if got, want := oc.AccessToken, "new-access"; got != want {
+		t.Errorf("wrong access token %q; want %q", got, want)
+	}
+	// The server didn't issue a new refresh token, so the old one is retained.
+	if got, want := oc.RefreshToken, "old-refresh"; got != want {
+		t.Errorf("wrong refresh token %q; want %q", got, want)
+	}
+}
+
+func TestDiscoOAuth2RefreshFunc_discovery(t *testing.T) {
+	// The refresh function is normally used by a credentials source that is
+	// also the Disco's own credentials source, so discovery for the refresh
+	// must not ask that source for credentials.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/terraform.json":
+			if got := r.Header.Get("Authorization"); got != "" {
+				t.Errorf("discovery request has Authorization header %q", got)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"login.v1": {"client": "terraform-cli", "grant_types": ["authz_code"], "authz": "/oauth/authorization", "token": "/oauth/token"}}`))
+		case "/oauth/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"new-access","token_type":"bearer","expires_in":3600}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	host, err := svchost.ForComparison(serverURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := testDisco()
+	store := &testWritableSource{creds: map[svchost.Hostname]auth.HostCredentialsWritable{
+		host: auth.HostCredentialsOAuth2{
+			AccessToken:  "old-access",
+			RefreshToken: "old-refresh",
+			Expiry:       time.Now().Add(-time.Hour),
+		},
+	}}
+	d.SetCredentialsSource(auth.RefreshingCredentialsSource(store, time.Minute, d.OAuth2RefreshFunc("login.v1")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	creds, err := d.CredentialsForHostContext(ctx, host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := creds.Token(), "new-access"; got != want {
+		t.Errorf("wrong token %q; want %q", got, want)
+	}
+}
+
+// testWritableSource is a writable in-memory credentials source.
+type testWritableSource struct {
+	mu    sync.Mutex
+	creds map[svchost.Hostname]auth.HostCredentialsWritable
+}
+
+func (s *testWritableSource) ForHost(host svchost.Hostname) (auth.HostCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if creds, ok := s.creds[host]; ok {
+		return creds, nil
+	}
+	return nil, nil
+}
+
+func (s *testWritableSource) StoreForHost(host svchost.Hostname, credentials auth.HostCredentialsWritable) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds[host] = credentials
+	return nil
+}
+
+func (s *testWritableSource) ForgetForHost(host svchost.Hostname) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.creds, host)
+	return nil
+}
+
+func TestDiscoTokenExchangeEndpoint(t *testing.T) {
+	t.Run("discovered without credentials", func(t *testing.T) {
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("Authorization"); got != "" {
+				t.Errorf("discovery request has Authorization header %q", got)
+			}
+			w.Header().Add("Content-Type", "application/json")
+			w.Write([]byte(`{"token-exchange.v1": "https://example.com/token"}`))
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco(WithCredentialsSource(auth.StaticCredentialsSource(map[svchost.Hostname]map[string]interface{}{
+			host: {"token": "abc123"},
+		})))
+		got, err := d.TokenExchangeEndpoint(host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got == nil || got.String() != "https://example.com/token" {
+			t.Errorf("wrong endpoint %s; want https://example.com/token", got)
+		}
+	})
+	t.Run("not provided", func(t *testing.T) {
+		host := svchost.Hostname("example.com")
+		d := testDisco()
+		d.ForceHostServices(host, map[string]interface{}{
+			"modules.v1": "/modules/",
+		})
+		got, err := d.TokenExchangeEndpoint(host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != nil {
+			t.Errorf("wrong endpoint %s; want nil", got)
+		}
+	})
+}
+
+func TestDiscoClientCredentialsTokenURL(t *testing.T) {
+	d := testDisco()
+	d.ForceHostServices("example.com", map[string]interface{}{
+		"login.v1": map[string]interface{}{
+			"client":      "terraform-cli",
+			"grant_types": []interface{}{"authz_code", "client_credentials"},
+			"authz":       "https://example.com/oauth/authorization",
+			"token":       "https://example.com/oauth/token",
+		},
+	})
+	d.ForceHostServices("example.net", map[string]interface{}{
+		"login.v1": map[string]interface{}{
+			"client": "terraform-cli",
+			"authz":  "https://example.net/oauth/authorization",
+			"token":  "https://example.net/oauth/token",
+		},
+	})
+	d.ForceHostServices("example.org", map[string]interface{}{})
+
+	tokenURL := d.ClientCredentialsTokenURL("login.v1")
+	tests := map[svchost.Hostname]string{
+		"example.com": "https://example.com/oauth/token",
+		"example.net": "", // client credentials grant not supported
+		"example.org": "", // login service not provided
+	}
+	for host, want := range tests {
+		t.Run(string(host), func(t *testing.T) {
+			got, err := tokenURL(host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if want == "" {
+				if got != nil {
+					t.Errorf("wrong result %s; want nil", got)
+				}
+				return
+			}
+			if got == nil || got.String() != want {
+				t.Errorf("wrong result %s; want %s", got, want)
+			}
+		})
+	}
+}
+
 func testServer(h func(w http.ResponseWriter, r *http.Request)) (portStr string, cleanup func()) {
 	server := httptest.NewTLSServer(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -461,3 +1223,40 @@ func testServer(h func(w http.ResponseWriter, r *http.Request)) (portStr string,
 
 	return portStr, cleanup
 }
+
+// testWaitForWaiters blocks until the given number of callers are waiting
+// for an in-progress discovery of the given hostname.
+func testWaitForWaiters(t *testing.T, d *Disco, hostname svchost.Hostname, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d.mu.Lock()
+		got := 0
+		if call, ok := d.inflight[hostname]; ok {
+			got = call.waiters
+		}
+		d.mu.Unlock()
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("wrong number of waiters %d; want %d", got, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// testTransport returns an HTTP transport that tolerates the
+// locally-generated TLS certificates we use for test URLs.
+func testTransport() *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+}
+
+// testDisco returns a discovery object that uses testTransport, configured
+// with the given additional options.
+func testDisco(opts ...Option) *Disco {
+	return NewWithOptions(append([]Option{WithTransport(testTransport())}, opts...)...)
+}
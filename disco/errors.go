@@ -0,0 +1,168 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// The errors in this file describe the ways that network-based discovery can
+// fail. Each carries the hostname that discovery was performed for, after
+// resolving any alias, and the URL of the request that failed, which differs
+// from the initial discovery URL if the server redirected the request.
+//
+// Errors that describe a response from the server also carry its status
+// code and the beginning of its body, for use in diagnostics. Callers should
+// not show the body snippet to end-users verbatim, because servers and
+// proxies sometimes return entire HTML pages for errors.
+
+// maxSnippetBytes is the number of bytes of a response body that an error
+// includes as its snippet.
+const maxSnippetBytes = 256
+
+// ErrServiceDiscoveryNetworkRequest represents the error that occurs when
+// the service discovery fails for an unknown network problem.
+//
+// Use errors.As with the result of Unwrap to find out more about the
+// problem: for example, a *net.DNSError for a hostname that doesn't resolve,
+// or a *tls.CertificateVerificationError for a server whose certificate
+// isn't trusted.
+type ErrServiceDiscoveryNetworkRequest struct {
+	Hostname svchost.Hostname
+	URL      *url.URL
+	Err      error
+}
+
+func (e ErrServiceDiscoveryNetworkRequest) Error() string {
+	wrappedError := fmt.Errorf("failed to request discovery document: %w", e.Err)
+	return wrappedError.Error()
+}
+
+func (e ErrServiceDiscoveryNetworkRequest) Unwrap() error {
+	return e.Err
+}
+
+// ErrServiceDiscoveryStatus is returned when the server responds to a
+// discovery request with a status code other than 200 (OK) or 404 (Not
+// Found), which mean that the host does or doesn't support Terraform
+// services respectively.
+//
+// If the status code is 401 (Unauthorized) or 403 (Forbidden), the error
+// is an *ErrServiceDiscoveryUnauthorized that wraps this one instead.
+type ErrServiceDiscoveryStatus struct {
+	Hostname   svchost.Hostname
+	URL        *url.URL
+	StatusCode int
+	Status     string
+	Snippet    string
+}
+
+func (e *ErrServiceDiscoveryStatus) Error() string {
+	return fmt.Sprintf("failed to request discovery document: %s", e.Status)
+}
+
+// ErrServiceDiscoveryUnauthorized is returned when the server responds to a
+// discovery request with status 401 (Unauthorized) or 403 (Forbidden),
+// which usually means that the credentials for the host are missing,
+// expired, or lack permission. Unwrap returns the corresponding
+// *ErrServiceDiscoveryStatus.
+type ErrServiceDiscoveryUnauthorized struct {
+	ErrServiceDiscoveryStatus
+
+	// HadCredentials is true if the request included credentials.
+	HadCredentials bool
+}
+
+func (e *ErrServiceDiscoveryUnauthorized) Error() string {
+	if e.HadCredentials {
+		return fmt.Sprintf("failed to request discovery document: %s (the credentials for %s were rejected)", e.Status, e.Hostname.ForDisplay())
+	}
+	return fmt.Sprintf("failed to request discovery document: %s (the request had no credentials for %s)", e.Status, e.Hostname.ForDisplay())
+}
+
+func (e *ErrServiceDiscoveryUnauthorized) Unwrap() error {
+	return &e.ErrServiceDiscoveryStatus
+}
+
+// ErrServiceDiscoveryContentType is returned when a discovery document is
+// served with a malformed Content-Type, or one other than application/json.
+// If the Content-Type is malformed, Unwrap returns the parsing error.
+type ErrServiceDiscoveryContentType struct {
+	Hostname    svchost.Hostname
+	URL         *url.URL
+	StatusCode  int
+	ContentType string
+	Snippet     string
+	Err         error
+}
+
+func (e *ErrServiceDiscoveryContentType) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("discovery URL has a malformed Content-Type %q", e.ContentType)
+	}
+	return fmt.Sprintf("discovery URL returned an unsupported Content-Type %q", e.ContentType)
+}
+
+func (e *ErrServiceDiscoveryContentType) Unwrap() error {
+	return e.Err
+}
+
+// ErrServiceDiscoveryTooLarge is returned when a discovery document is
+// larger than the limit set with WithMaxDocumentSize. Size is the size of
+// the document given in its Content-Length header, or -1 if the server
+// didn't announce the size in advance.
+type ErrServiceDiscoveryTooLarge struct {
+	Hostname   svchost.Hostname
+	URL        *url.URL
+	StatusCode int
+	Size       int64
+	Limit      int64
+}
+
+func (e *ErrServiceDiscoveryTooLarge) Error() string {
+	if e.Size < 0 {
+		return fmt.Sprintf("discovery doc response is too large (limit %d bytes)", e.Limit)
+	}
+	return fmt.Sprintf("discovery doc response is too large (got %d bytes; limit %d)", e.Size, e.Limit)
+}
+
+// ErrServiceDiscoveryMalformed is returned when a discovery document is not
+// a valid JSON object. Unwrap returns the error from the JSON decoder.
+type ErrServiceDiscoveryMalformed struct {
+	Hostname   svchost.Hostname
+	URL        *url.URL
+	StatusCode int
+	Snippet    string
+	Err        error
+}
+
+func (e *ErrServiceDiscoveryMalformed) Error() string {
+	return fmt.Sprintf("failed to decode discovery document as a JSON object: %s", e.Err)
+}
+
+func (e *ErrServiceDiscoveryMalformed) Unwrap() error {
+	return e.Err
+}
+
+// responseSnippet returns the beginning of the given response body, for use
+// as the snippet in an error, truncated to maxSnippetBytes and with any
+// invalid UTF-8 replaced.
+func responseSnippet(body []byte) string {
+	if len(body) > maxSnippetBytes {
+		body = body[:maxSnippetBytes]
+	}
+	return strings.ToValidUTF8(string(body), "\uFFFD")
+}
+
+// readSnippet reads the beginning of the given response body, for use as
+// the snippet in an error. Errors are ignored, because the snippet is only
+// for diagnostics.
+func readSnippet(body io.Reader) string {
+	buf, _ := io.ReadAll(io.LimitReader(body, maxSnippetBytes))
+	return responseSnippet(buf)
+}
@@ -0,0 +1,144 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// FileCache is a Cache that stores each discovery result as a JSON file in
+// a directory on the local filesystem.
+//
+// Files are replaced atomically, so concurrent processes sharing the same
+// directory each see either the old or the new version of an entry. If two
+// processes update the same entry at the same time, the last write wins.
+type FileCache struct {
+	dir string
+}
+
+var _ Cache = (*FileCache)(nil)
+
+// NewFileCache returns a FileCache that stores its files in the given
+// directory. The directory is created when the first entry is stored, if it
+// does not already exist.
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{dir: dir}
+}
+
+// fileCacheEntry is the JSON representation of a CacheEntry in a file.
+type fileCacheEntry struct {
+	URL          string                 `json:"url"`
+	Services     map[string]interface{} `json:"services"`
+	FetchedAt    time.Time              `json:"fetched_at"`
+	ExpiresAt    time.Time              `json:"expires_at"`
+	ETag         string                 `json:"etag,omitempty"`
+	LastModified string                 `json:"last_modified,omitempty"`
+}
+
+// Get implements Cache by reading the file for the given hostname, returning
+// nil if it does not exist.
+func (c *FileCache) Get(hostname svchost.Hostname) (*CacheEntry, error) {
+	filename, err := c.filename(hostname)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var raw fileCacheEntry
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return nil, fmt.Errorf("malformed discovery cache file for %s: %w", hostname, err)
+	}
+	u, err := url.Parse(raw.URL)
+	if err != nil || !u.IsAbs() {
+		return nil, fmt.Errorf("malformed discovery cache file for %s: invalid URL %q", hostname, raw.URL)
+	}
+	return &CacheEntry{
+		URL:          u,
+		Services:     raw.Services,
+		FetchedAt:    raw.FetchedAt,
+		ExpiresAt:    raw.ExpiresAt,
+		ETag:         raw.ETag,
+		LastModified: raw.LastModified,
+	}, nil
+}
+
+// Put implements Cache by atomically replacing the file for the given
+// hostname.
+func (c *FileCache) Put(hostname svchost.Hostname, entry *CacheEntry) error {
+	filename, err := c.filename(hostname)
+	if err != nil {
+		return err
+	}
+	src, err := json.MarshalIndent(fileCacheEntry{
+		URL:          entry.URL.String(),
+		Services:     entry.Services,
+		FetchedAt:    entry.FetchedAt,
+		ExpiresAt:    entry.ExpiresAt,
+		ETag:         entry.ETag,
+		LastModified: entry.LastModified,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't serialize discovery result for %s: %w", hostname, err)
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	_, err = f.Write(src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		os.Remove(tmpName) //nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+// Delete implements Cache by removing the file for the given hostname.
+func (c *FileCache) Delete(hostname svchost.Hostname) error {
+	filename, err := c.filename(hostname)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// filename returns the path of the file for the given hostname. Hostnames in
+// comparison form cannot contain underscores, so replacing the port
+// separator, which some filesystems don't allow, with one cannot cause
+// collisions.
+func (c *FileCache) filename(hostname svchost.Hostname) (string, error) {
+	name := string(hostname)
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid hostname %q for discovery cache", hostname)
+	}
+	return filepath.Join(c.dir, strings.ReplaceAll(name, ":", "_")+".json"), nil
+}
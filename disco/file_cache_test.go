@@ -0,0 +1,107 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestFileCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "disco")
+	cache := NewFileCache(dir)
+	host := svchost.Hostname("example.com:8443")
+
+	t.Run("missing", func(t *testing.T) {
+		entry, err := cache.Get(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != nil {
+			t.Errorf("got entry %#v; want nil", entry)
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		want := &CacheEntry{
+			URL: &url.URL{
+				Scheme: "https",
+				Host:   "example.com:8443",
+				Path:   "/.well-known/terraform.json",
+			},
+			Services: map[string]interface{}{
+				"thingy.v1": "https://example.com/thingy/",
+			},
+			FetchedAt:    time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			ExpiresAt:    time.Date(2025, 6, 1, 13, 0, 0, 0, time.UTC),
+			ETag:         `"v1"`,
+			LastModified: "Sun, 01 Jun 2025 11:00:00 GMT",
+		}
+		if err := cache.Put(host, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := cache.Get(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong entry\n%s", diff)
+		}
+
+		// Only the entry's own file should remain in the directory.
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0].Name() != "example.com_8443.json" {
+			t.Errorf("wrong files in cache directory: %v", files)
+		}
+	})
+	t.Run("no discovery document", func(t *testing.T) {
+		want := &CacheEntry{
+			URL: &url.URL{Scheme: "https", Host: "empty.example.com", Path: "/.well-known/terraform.json"},
+		}
+		if err := cache.Put("empty.example.com", want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := cache.Get("empty.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Services != nil {
+			t.Errorf("wrong services %#v; want nil", got.Services)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		if err := cache.Delete(host); err != nil {
+			t.Fatal(err)
+		}
+		if entry, err := cache.Get(host); err != nil || entry != nil {
+			t.Errorf("entry still present after delete: %#v, %v", entry, err)
+		}
+		if err := cache.Delete(host); err != nil {
+			t.Errorf("deleting a missing entry failed: %s", err)
+		}
+	})
+	t.Run("malformed file", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, "bad.example.com.json"), []byte(`{"url": 1}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cache.Get("bad.example.com"); err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+	t.Run("invalid hostname", func(t *testing.T) {
+		for _, host := range []svchost.Hostname{"", "../example.com", `a\b`} {
+			if _, err := cache.Get(host); err == nil {
+				t.Errorf("no error for %q", host)
+			}
+		}
+	})
+}
@@ -3,6 +3,7 @@
 package disco
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -24,6 +25,15 @@ type Host struct {
 	hostname  string
 	services  map[string]interface{}
 	transport http.RoundTripper
+	logger    *log.Logger
+
+	// Caching information from the discovery response. expiresAt is zero
+	// for a host that never expires, such as one with forced services.
+	fetchedAt    time.Time
+	expiresAt    time.Time
+	etag         string
+	lastModified string
+	noStore      bool
 }
 
 // Constraints represents the version constraints of a service.
@@ -163,7 +173,7 @@ func (h *Host) ServiceOAuthClient(id string) (*OAuthClient, error) {
 		raw = v[0]
 	default:
 		// Debug message because raw Go types don't belong in our UI.
-		log.Printf("[DEBUG] The definition for %s has Go type %T", id, h.services[id])
+		h.logf("[DEBUG] The definition for %s has Go type %T", id, h.services[id])
 		return nil, fmt.Errorf("service %s must be declared with an object value in the service discovery document", id)
 	}
 
@@ -240,7 +250,7 @@ func (h *Host) ServiceOAuthClient(id string) (*OAuthClient, error) {
 				ports[i] = uint16(v)
 			default:
 				// Debug message because raw Go types don't belong in our UI.
-				log.Printf("[DEBUG] Port value %d has Go type %T", i, portsRaw[i])
+				h.logf("[DEBUG] Port value %d has Go type %T", i, portsRaw[i])
 				return nil, invalidPortsErr
 			}
 		}
@@ -309,6 +319,13 @@ func (h *Host) parseURL(urlStr string) (*url.URL, error) {
 // When checkpoint is disabled or when a 404 is returned after making the
 // HTTP call, an ErrNoVersionConstraints error will be returned.
 func (h *Host) VersionConstraints(id, product string) (*Constraints, error) {
+	return h.VersionConstraintsContext(context.Background(), id, product)
+}
+
+// VersionConstraintsContext is like VersionConstraints, but the HTTP request
+// for the constraints is aborted if the given context is cancelled or its
+// deadline passes.
+func (h *Host) VersionConstraintsContext(ctx context.Context, id, product string) (*Constraints, error) {
 	svc, _, err := parseServiceID(id)
 	if err != nil {
 		return nil, err
@@ -332,32 +349,15 @@ func (h *Host) VersionConstraints(id, product string) (*Constraints, error) {
 	}
 
 	// Check if we have an exact (service and version) match.
-	//nolint:nestif
 	if _, ok := h.services[id].(string); !ok {
-		// If we don't have an exact match, we search for all matching
-		// services and then use the service ID of the latest version.
-		var services []string
-		for serviceID := range h.services {
-			if strings.HasPrefix(serviceID, svc+".") {
-				services = append(services, serviceID)
-			}
-		}
-
-		if len(services) == 0 {
+		// If we don't have an exact match, we use the service ID of the
+		// latest version of the service.
+		latest := latestVersion(h.SupportedVersions(svc), nil)
+		if latest == nil {
 			// No discovered services match the requested service.
 			return nil, &ErrServiceNotProvided{hostname: h.hostname, service: svc}
 		}
-
-		// Set id to the latest service ID we found.
-		var latest *version.Version
-		for _, serviceID := range services {
-			if _, ver, err := parseServiceID(serviceID); err == nil {
-				if latest == nil || latest.LessThan(ver) {
-					id = serviceID
-					latest = ver
-				}
-			}
-		}
+		id = ServiceID{Name: svc, Version: latest}.String()
 	}
 
 	// Set a default timeout of 1 sec for the versions request (in milliseconds)
@@ -378,13 +378,13 @@ func (h *Host) VersionConstraints(id, product string) (*Constraints, error) {
 	u.RawQuery = v.Encode()
 
 	// Create a new request.
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create version constraints request: %v", err)
 	}
 	req.Header.Set("Accept", "application/json")
 
-	log.Printf("[DEBUG] Retrieve version constraints for service %s and product %s", id, product)
+	h.logf("[DEBUG] Retrieve version constraints for service %s and product %s", id, product)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -409,6 +409,60 @@ func (h *Host) VersionConstraints(id, product string) (*Constraints, error) {
 	return result, nil
 }
 
+// FetchedAt returns the time at which the discovery document for the host
+// was fetched, or most recently revalidated. For a host whose services were
+// given to Disco.ForceHostServices, it is the time of that call.
+func (h *Host) FetchedAt() time.Time {
+	return h.fetchedAt
+}
+
+// ExpiresAt returns the time after which the Disco that returned the host
+// will no longer use it without first revalidating it, as decided by the
+// HTTP caching headers of the discovery response. The result is the zero
+// time for a host that does not expire, such as one whose services were
+// given to Disco.ForceHostServices.
+func (h *Host) ExpiresAt() time.Time {
+	return h.expiresAt
+}
+
+// fresh returns true if the receiver may be used at the given time without
+// revalidation.
+func (h *Host) fresh(now time.Time) bool {
+	return h.expiresAt.IsZero() || now.Before(h.expiresAt)
+}
+
+// hasValidators returns true if the receiver can be revalidated using a
+// conditional request.
+func (h *Host) hasValidators() bool {
+	return h.etag != "" || h.lastModified != ""
+}
+
+// setCachePolicy updates the caching information of the receiver from the
+// given policy for a response received at the given time. Validators that
+// the policy lacks are retained, because a server need not repeat them in a
+// response to a conditional request.
+func (h *Host) setCachePolicy(policy cachePolicy, fetchedAt time.Time) {
+	h.fetchedAt = fetchedAt
+	h.expiresAt = policy.expiresAt
+	h.noStore = policy.noStore
+	if policy.etag != "" {
+		h.etag = policy.etag
+	}
+	if policy.lastModified != "" {
+		h.lastModified = policy.lastModified
+	}
+}
+
+// logf writes a diagnostic message using the logger of the Disco that
+// created the receiver, or the standard logger if there is none.
+func (h *Host) logf(format string, args ...interface{}) {
+	if h.logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	h.logger.Printf(format, args...)
+}
+
 func parseServiceID(id string) (string, *version.Version, error) {
 	parts := strings.SplitN(id, ".", 2)
 	if len(parts) != 2 {
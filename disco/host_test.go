@@ -110,6 +110,15 @@ func TestHostServiceOAuthClient(t *testing.T) {
 				"token":       "./token",
 				"grant_types": []interface{}{"password"},
 			},
+			"clientcredentials.v1": map[string]interface{}{
+				"client":      "clientcredentials",
+				"token":       "./token",
+				"grant_types": []interface{}{"client_credentials"},
+			},
+			"clientcredentialsmissingtoken.v1": map[string]interface{}{
+				"client":      "clientcredentialsmissingtoken",
+				"grant_types": []interface{}{"client_credentials"},
+			},
 			"absolute.v1": map[string]interface{}{
 				"client": "absolute",
 				"authz":  "http://example.net/foo/authz",
@@ -235,6 +244,22 @@ func TestHostServiceOAuthClient(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"clientcredentials.v1",
+			&OAuthClient{
+				ID:                  "clientcredentials",
+				TokenURL:            mustURL(t, "https://example.com/disco/token"),
+				MinPort:             1024,
+				MaxPort:             65535,
+				SupportedGrantTypes: NewOAuthGrantTypeSet("client_credentials"),
+			},
+			"",
+		},
+		{
+			"clientcredentialsmissingtoken.v1",
+			nil,
+			`service clientcredentialsmissingtoken.v1 definition is missing required property "token"`,
+		},
 		{
 			"absolute.v1",
 			&OAuthClient{
@@ -383,7 +408,7 @@ func TestVersionConstrains(t *testing.T) {
 		host := Host{
 			discoURL:  baseURL,
 			hostname:  "test-server",
-			transport: httpTransport,
+			transport: testTransport(),
 			services: map[string]interface{}{
 				"thingy.v1":   "/api/v1/",
 				"thingy.v2":   "/api/v2/",
@@ -431,7 +456,7 @@ func TestVersionConstrains(t *testing.T) {
 		host := Host{
 			discoURL:  baseURL,
 			hostname:  "test-server",
-			transport: httpTransport,
+			transport: testTransport(),
 			services: map[string]interface{}{
 				"thingy.v2":   "/api/v2/",
 				"thingy.v3":   "/api/v3/",
@@ -460,7 +485,7 @@ func TestVersionConstrains(t *testing.T) {
 		host := Host{
 			discoURL:  baseURL,
 			hostname:  "test-server",
-			transport: httpTransport,
+			transport: testTransport(),
 			services: map[string]interface{}{
 				"versions.v1": "https://localhost/v1/versions/",
 			},
@@ -479,7 +504,7 @@ func TestVersionConstrains(t *testing.T) {
 		host := Host{
 			discoURL:  baseURL,
 			hostname:  "test-server",
-			transport: httpTransport,
+			transport: testTransport(),
 			services: map[string]interface{}{
 				"thingy.v1":   "/api/v1/",
 				"versions.v1": "https://localhost" + portStr + "/v1/non-existent/",
@@ -501,7 +526,7 @@ func TestVersionConstrains(t *testing.T) {
 		host := Host{
 			discoURL:  baseURL,
 			hostname:  "test-server",
-			transport: httpTransport,
+			transport: testTransport(),
 			services: map[string]interface{}{
 				"thingy.v1":   "/api/v1/",
 				"versions.v1": "https://localhost/v1/versions/",
@@ -518,7 +543,7 @@ func TestVersionConstrains(t *testing.T) {
 		host := Host{
 			discoURL:  baseURL,
 			hostname:  "test-server",
-			transport: httpTransport,
+			transport: testTransport(),
 			services: map[string]interface{}{
 				"thingy.v1": "/api/v1/",
 			},
@@ -534,7 +559,7 @@ func TestVersionConstrains(t *testing.T) {
 		host := Host{
 			discoURL:  baseURL,
 			hostname:  "test-server",
-			transport: httpTransport,
+			transport: testTransport(),
 			services: map[string]interface{}{
 				"thingy.v1":   "/api/v1/",
 				"versions.v2": "https://localhost/v2/versions/",
@@ -0,0 +1,108 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is how long a discovery result is cached when the discovery
+// response has no caching headers, unless overridden with WithDefaultTTL.
+const DefaultTTL = 1 * time.Hour
+
+// cachePolicy describes how a discovery response may be cached, as decided
+// by responseCachePolicy.
+type cachePolicy struct {
+	// noStore is true if the response must not be cached at all.
+	noStore bool
+
+	// expiresAt is the time after which the response must be revalidated
+	// before it is used again.
+	expiresAt time.Time
+
+	// etag and lastModified are the validators to use when revalidating
+	// the response with a conditional request. Either may be empty.
+	etag         string
+	lastModified string
+}
+
+// responseCachePolicy returns the caching policy for the given discovery
+// response, received at the given time, according to the subset of the
+// HTTP caching rules in RFC 9111 that apply to a private cache:
+//
+//   - Cache-Control: no-store prevents caching.
+//   - Cache-Control: no-cache or max-age give the freshness lifetime, reduced
+//     by the Age header if present.
+//   - Otherwise, Expires gives the expiry time, adjusted by the difference
+//     between our clock and the response's Date header, if present.
+//   - Otherwise, the response is fresh for defaultTTL.
+func responseCachePolicy(header http.Header, now time.Time, defaultTTL time.Duration) cachePolicy {
+	policy := cachePolicy{
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+	}
+
+	directives := parseCacheControl(header.Values("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		policy.noStore = true
+		return policy
+	}
+
+	if _, ok := directives["no-cache"]; ok {
+		policy.expiresAt = now
+		return policy
+	}
+
+	if raw, ok := directives["max-age"]; ok {
+		maxAge, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || maxAge < 0 {
+			// RFC 9111 asks us to treat an invalid max-age as stale.
+			policy.expiresAt = now
+			return policy
+		}
+		if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && age > 0 {
+			maxAge -= age
+		}
+		policy.expiresAt = now.Add(time.Duration(maxAge) * time.Second)
+		return policy
+	}
+
+	if raw := header.Get("Expires"); raw != "" {
+		expires, err := http.ParseTime(raw)
+		if err != nil {
+			// Invalid values, including the common "0", mean already expired.
+			policy.expiresAt = now
+			return policy
+		}
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			policy.expiresAt = now.Add(expires.Sub(date))
+		} else {
+			policy.expiresAt = expires
+		}
+		return policy
+	}
+
+	policy.expiresAt = now.Add(defaultTTL)
+	return policy
+}
+
+// parseCacheControl parses the given Cache-Control header values into a map
+// from lowercase directive names to their values, which are empty for
+// directives without a value.
+func parseCacheControl(values []string) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			directives[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return directives
+}
@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResponseCachePolicy(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	date := now.Add(-time.Hour) // the server's clock is an hour behind ours
+
+	tests := map[string]struct {
+		header http.Header
+		want   cachePolicy
+	}{
+		"no headers": {
+			http.Header{},
+			cachePolicy{expiresAt: now.Add(DefaultTTL)},
+		},
+		"no-store": {
+			http.Header{"Cache-Control": {"private, no-store"}},
+			cachePolicy{noStore: true},
+		},
+		"no-cache": {
+			http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"abc"`}},
+			cachePolicy{expiresAt: now, etag: `"abc"`},
+		},
+		"max-age": {
+			http.Header{"Cache-Control": {"public, max-age=300"}},
+			cachePolicy{expiresAt: now.Add(5 * time.Minute)},
+		},
+		"max-age with Age": {
+			http.Header{"Cache-Control": {"max-age=300"}, "Age": {"100"}},
+			cachePolicy{expiresAt: now.Add(200 * time.Second)},
+		},
+		"max-age quoted": {
+			http.Header{"Cache-Control": {`Max-Age="60"`}},
+			cachePolicy{expiresAt: now.Add(time.Minute)},
+		},
+		"max-age invalid": {
+			http.Header{"Cache-Control": {"max-age=soon"}},
+			cachePolicy{expiresAt: now},
+		},
+		"max-age overrides Expires": {
+			http.Header{
+				"Cache-Control": {"max-age=60"},
+				"Expires":       {date.Add(time.Hour).Format(http.TimeFormat)},
+			},
+			cachePolicy{expiresAt: now.Add(time.Minute)},
+		},
+		"Expires with Date": {
+			http.Header{
+				"Date":    {date.Format(http.TimeFormat)},
+				"Expires": {date.Add(10 * time.Minute).Format(http.TimeFormat)},
+			},
+			cachePolicy{expiresAt: now.Add(10 * time.Minute)},
+		},
+		"Expires without Date": {
+			http.Header{"Expires": {now.Add(10 * time.Minute).Format(http.TimeFormat)}},
+			cachePolicy{expiresAt: now.Add(10 * time.Minute)},
+		},
+		"Expires invalid": {
+			http.Header{"Expires": {"0"}},
+			cachePolicy{expiresAt: now},
+		},
+		"validators": {
+			http.Header{
+				"Etag":          {`W/"v1"`},
+				"Last-Modified": {date.Format(http.TimeFormat)},
+			},
+			cachePolicy{
+				expiresAt:    now.Add(DefaultTTL),
+				etag:         `W/"v1"`,
+				lastModified: date.Format(http.TimeFormat),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := responseCachePolicy(test.header, now, DefaultTTL)
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(cachePolicy{})); diff != "" {
+				t.Errorf("wrong policy\n%s", diff)
+			}
+		})
+	}
+}
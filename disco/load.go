@@ -0,0 +1,159 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+// LoadServicesDir reads discovery documents from the given directory, which
+// must contain a subdirectory for each host named after its hostname and
+// containing a file named terraform.json, and registers each one as if
+// given to ForceHostServices. Entries whose names begin with a period, and
+// files other than directories, are ignored.
+//
+// Each hostname is validated with svchost.ForComparison, so a directory may
+// be named using either the display or the comparison form of a hostname.
+// Relative URLs in the documents are resolved against the URL that
+// network-based discovery would have used for the host.
+//
+// If any of the documents or hostnames is invalid then no hosts are
+// registered, and the returned error describes all of the problems.
+func (d *Disco) LoadServicesDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read service discovery directory: %w", err)
+	}
+
+	loader := newServicesLoader()
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || !entry.IsDir() {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name(), "terraform.json")
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			loader.errorf("failed to read discovery document for %s: %w", entry.Name(), err)
+			continue
+		}
+		loader.add(entry.Name(), filename, src)
+	}
+	return loader.apply(d)
+}
+
+// LoadServicesBundle reads discovery documents from the given bundle file
+// and registers each one as if given to ForceHostServices. The bundle must
+// contain a JSON object whose property names are hostnames and whose values
+// are the discovery documents for those hosts.
+//
+// Hostnames and documents are validated and relative URLs are resolved in
+// the same way as for LoadServicesDir, and as there, no hosts are registered
+// if any of them is invalid.
+func (d *Disco) LoadServicesBundle(filename string) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read service discovery bundle: %w", err)
+	}
+	var bundle map[string]json.RawMessage
+	if err := json.Unmarshal(src, &bundle); err != nil {
+		return fmt.Errorf("service discovery bundle %s must be a JSON object: %w", filename, err)
+	}
+
+	// Sort the hostnames so that errors are reported in a consistent order.
+	names := make([]string, 0, len(bundle))
+	for name := range bundle {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	loader := newServicesLoader()
+	for _, name := range names {
+		loader.add(name, fmt.Sprintf("%s (host %q)", filename, name), bundle[name])
+	}
+	return loader.apply(d)
+}
+
+// servicesLoader collects and validates discovery documents for
+// LoadServicesDir and LoadServicesBundle.
+type servicesLoader struct {
+	hosts   map[svchost.Hostname]map[string]interface{}
+	sources map[svchost.Hostname]string
+	errs    []error
+}
+
+func newServicesLoader() *servicesLoader {
+	return &servicesLoader{
+		hosts:   make(map[svchost.Hostname]map[string]interface{}),
+		sources: make(map[svchost.Hostname]string),
+	}
+}
+
+func (l *servicesLoader) errorf(format string, args ...interface{}) {
+	l.errs = append(l.errs, fmt.Errorf(format, args...))
+}
+
+// add validates the given hostname and discovery document, read from the
+// given source, and records them if they are valid.
+func (l *servicesLoader) add(name, source string, src []byte) {
+	hostname, err := svchost.ForComparison(name)
+	if err != nil {
+		l.errorf("invalid hostname %q in %s: %w", name, source, err)
+		return
+	}
+	if prev, exists := l.sources[hostname]; exists {
+		l.errorf("duplicate discovery document for %s in %s; already loaded from %s", hostname.ForDisplay(), source, prev)
+		return
+	}
+
+	var services map[string]interface{}
+	if err := json.Unmarshal(src, &services); err != nil || services == nil {
+		if err == nil {
+			err = errors.New("document is null")
+		}
+		l.errorf("discovery document for %s in %s must be a JSON object: %w", hostname.ForDisplay(), source, err)
+		return
+	}
+
+	ids := make([]string, 0, len(services))
+	for id := range services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	valid := true
+	for _, id := range ids {
+		if s, ok := services[id].(string); ok {
+			if _, err := url.Parse(s); err != nil {
+				l.errorf("invalid URL for %s in discovery document for %s in %s: %w", id, hostname.ForDisplay(), source, err)
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return
+	}
+
+	l.hosts[hostname] = services
+	l.sources[hostname] = source
+}
+
+// apply registers the collected hosts with the given Disco if there were no
+// errors, or otherwise returns all of the errors.
+func (l *servicesLoader) apply(d *Disco) error {
+	if len(l.errs) != 0 {
+		return errors.Join(l.errs...)
+	}
+	for hostname, services := range l.hosts {
+		d.ForceHostServices(hostname, services)
+	}
+	return nil
+}
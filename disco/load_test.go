@@ -0,0 +1,151 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestLoadServicesDir(t *testing.T) {
+	writeDoc := func(t *testing.T, dir, name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "terraform.json"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		dir := t.TempDir()
+		writeDoc(t, dir, "example.com", `{"thingy.v1": "/thingy/"}`)
+		writeDoc(t, dir, "Other.Example.NET", `{"thingy.v1": "https://example.net/thingy/"}`)
+		writeDoc(t, dir, ".hidden", `not json`)
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		d := New()
+		if err := d.LoadServicesDir(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := map[svchost.Hostname]string{
+			"example.com":       "https://example.com/thingy/",
+			"other.example.net": "https://example.net/thingy/",
+		}
+		for hostname, want := range tests {
+			got, err := d.DiscoverServiceURL(hostname, "thingy.v1")
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", hostname, err)
+			}
+			if got.String() != want {
+				t.Errorf("wrong URL for %s %q; want %q", hostname, got, want)
+			}
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		writeDoc(t, dir, "example.com", `{"thingy.v1": "/thingy/"}`)
+		writeDoc(t, dir, "not a hostname", `{}`)
+		writeDoc(t, dir, "array.example.com", `[]`)
+		writeDoc(t, dir, "url.example.com", `{"thingy.v1": "http://[::1"}`)
+		if err := os.MkdirAll(filepath.Join(dir, "empty.example.com"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		d := New()
+		err := d.LoadServicesDir(dir)
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		for _, want := range []string{
+			`invalid hostname "not a hostname"`,
+			"discovery document for array.example.com",
+			"invalid URL for thingy.v1",
+			"failed to read discovery document for empty.example.com",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error does not mention %q\n%s", want, err)
+			}
+		}
+
+		// No hosts are registered if any are invalid.
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if len(d.hostCache) != 0 {
+			t.Errorf("hosts were registered despite errors")
+		}
+	})
+}
+
+func TestLoadServicesBundle(t *testing.T) {
+	writeBundle := func(t *testing.T, content string) string {
+		t.Helper()
+		filename := filepath.Join(t.TempDir(), "bundle.json")
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		filename := writeBundle(t, `{
+			"example.com": {"thingy.v1": "/thingy/"},
+			"example.net:8443": {"thingy.v1": "thingy/"}
+		}`)
+
+		d := New()
+		if err := d.LoadServicesBundle(filename); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := map[svchost.Hostname]string{
+			"example.com":      "https://example.com/thingy/",
+			"example.net:8443": "https://example.net:8443/.well-known/thingy/",
+		}
+		for hostname, want := range tests {
+			got, err := d.DiscoverServiceURL(hostname, "thingy.v1")
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", hostname, err)
+			}
+			if got.String() != want {
+				t.Errorf("wrong URL for %s %q; want %q", hostname, got, want)
+			}
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		filename := writeBundle(t, `{
+			"example.com": {"thingy.v1": "/thingy/"},
+			"EXAMPLE.com": {},
+			"bad host": {},
+			"null.example.com": null
+		}`)
+
+		d := New()
+		err := d.LoadServicesBundle(filename)
+		if err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		for _, want := range []string{
+			"duplicate discovery document for example.com",
+			`invalid hostname "bad host"`,
+			"discovery document for null.example.com",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error does not mention %q\n%s", want, err)
+			}
+		}
+	})
+	t.Run("not an object", func(t *testing.T) {
+		if err := New().LoadServicesBundle(writeBundle(t, `[]`)); err == nil {
+			t.Error("completed successfully; want error")
+		}
+	})
+}
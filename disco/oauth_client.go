@@ -75,6 +75,19 @@ func (c *OAuthClient) Endpoint() oauth2.Endpoint {
 	return ep
 }
 
+// Config returns an oauth2.Config value ready to be used with the oauth2
+// library, representing the client ID, endpoints and scopes from the receiver.
+//
+// The result has no RedirectURL, because that depends on which port from the
+// range given by MinPort and MaxPort the caller selects.
+func (c *OAuthClient) Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID: c.ID,
+		Endpoint: c.Endpoint(),
+		Scopes:   c.Scopes,
+	}
+}
+
 // OAuthGrantType is an enumeration of grant type strings that a host can
 // advertise support for.
 //
@@ -91,6 +104,10 @@ const (
 	// OAuthOwnerPasswordGrant represents a resource owner password
 	// credentials grant, as defined in IETF RFC 6749 section 4.3.
 	OAuthOwnerPasswordGrant = OAuthGrantType("password")
+
+	// OAuthClientCredentialsGrant represents a client credentials grant, as
+	// defined in IETF RFC 6749 section 4.4.
+	OAuthClientCredentialsGrant = OAuthGrantType("client_credentials")
 )
 
 // UsesAuthorizationEndpoint returns true if the receiving grant type makes
@@ -102,6 +119,8 @@ func (t OAuthGrantType) UsesAuthorizationEndpoint() bool {
 		return true
 	case OAuthOwnerPasswordGrant:
 		return false
+	case OAuthClientCredentialsGrant:
+		return false
 	default:
 		// We'll default to false so that we don't impose any requirements
 		// on any grant type keywords that might be defined for future
@@ -119,6 +138,8 @@ func (t OAuthGrantType) UsesTokenEndpoint() bool {
 		return true
 	case OAuthOwnerPasswordGrant:
 		return true
+	case OAuthClientCredentialsGrant:
+		return true
 	default:
 		// We'll default to false so that we don't impose any requirements
 		// on any grant type keywords that might be defined for future
@@ -0,0 +1,166 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-svchost/auth"
+)
+
+const (
+	// DefaultTimeout is the time limit for a discovery request, including
+	// any redirects, unless overridden with WithTimeout. It is
+	// arbitrary-but-small, to prevent UI "hangs" during discovery.
+	DefaultTimeout = 11 * time.Second
+
+	// DefaultMaxRedirects is the number of redirects that a discovery request
+	// may follow unless overridden with WithMaxRedirects. It is
+	// arbitrary-but-small, to prevent runaway redirect loops.
+	DefaultMaxRedirects = 3
+
+	// DefaultMaxDocumentSize is the largest discovery document, in bytes,
+	// that will be accepted unless overridden with WithMaxDocumentSize. It
+	// prevents abusive services from using loads of our memory.
+	DefaultMaxDocumentSize = 1 * 1024 * 1024
+)
+
+// Option is a functional option that configures a Disco created with
+// NewWithOptions.
+type Option func(*Disco)
+
+// WithTimeout sets the time limit for each discovery request, including any
+// redirects and reading the response body. A zero duration means no limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Disco) {
+		d.timeout = timeout
+	}
+}
+
+// WithMaxRedirects sets the number of redirects that a discovery request may
+// follow before discovery fails. Zero means that redirects are not followed.
+func WithMaxRedirects(n int) Option {
+	return func(d *Disco) {
+		d.maxRedirects = n
+	}
+}
+
+// WithMaxDocumentSize sets the largest discovery document, in bytes, that
+// will be accepted. Discovery fails for a host with a larger document.
+func WithMaxDocumentSize(n int64) Option {
+	return func(d *Disco) {
+		d.maxDocBytes = n
+	}
+}
+
+// WithDefaultTTL sets how long a discovery result is cached when the
+// discovery response has no HTTP caching headers. Caching headers in the
+// response always take precedence.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(d *Disco) {
+		d.defaultTTL = ttl
+	}
+}
+
+// WithCache sets a persistent cache for discovery results, such as a
+// FileCache, to use in addition to the Disco's in-memory cache. By default
+// there is none, and results are cached only in memory.
+//
+// Results from the persistent cache are subject to the same expiry and
+// revalidation rules as results fetched from the network, so an expired
+// entry is still useful for revalidation, and with WithStaleIfError it can
+// be used when the network is unavailable.
+func WithCache(cache Cache) Option {
+	return func(d *Disco) {
+		d.cache = cache
+	}
+}
+
+// WithStaleIfError allows the Disco to return an expired discovery result,
+// from its in-memory or persistent cache, if a request to fetch a new
+// result fails due to a network problem and the cached result expired no
+// longer than the given duration ago. By default, expired results are never
+// used.
+func WithStaleIfError(maxStale time.Duration) Option {
+	return func(d *Disco) {
+		d.staleIfError = maxStale
+	}
+}
+
+// WithRetryPolicy sets the policy for retrying discovery requests that fail
+// for reasons that are likely to be transient. By default, failed requests
+// are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(d *Disco) {
+		d.retry = policy
+	}
+}
+
+// WithTransport sets the HTTP transport used for discovery requests and for
+// other requests the Disco makes on behalf of the discovered hosts. This is
+// the initial value of the Disco's Transport field.
+//
+// Unlike the default transport, the given transport is used verbatim and so
+// does not set a default User-Agent header. Use SetUserAgent to add one.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(d *Disco) {
+		d.Transport = transport
+	}
+}
+
+// WithHTTPClient is like WithTransport, but uses the transport of the given
+// client, or http.DefaultTransport if it has none. If the client has a
+// non-zero Timeout then that is used as if given to WithTimeout. A nil client
+// selects http.DefaultTransport and leaves the timeout unchanged.
+//
+// The client's redirect policy and cookie jar are not used, because
+// discovery applies its own redirect limit and never needs cookies.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Disco) {
+		if client == nil {
+			d.Transport = http.DefaultTransport
+			return
+		}
+		d.Transport = client.Transport
+		if d.Transport == nil {
+			d.Transport = http.DefaultTransport
+		}
+		if client.Timeout != 0 {
+			d.timeout = client.Timeout
+		}
+	}
+}
+
+// WithLogger sets the logger that the Disco, and the Host objects it
+// returns, use for diagnostic messages. By default, messages are written
+// using the standard logger of the log package. A nil logger suppresses
+// them, as does a logger that writes to io.Discard.
+func WithLogger(logger *log.Logger) Option {
+	return func(d *Disco) {
+		if logger == nil {
+			logger = log.New(io.Discard, "", 0)
+		}
+		d.logger = logger
+	}
+}
+
+// WithClock sets the function that the Disco calls to determine the current
+// time, such as when deciding whether a cached discovery result has expired.
+// The default is time.Now. This is intended mainly for tests that need to
+// simulate the passage of time.
+func WithClock(now func() time.Time) Option {
+	return func(d *Disco) {
+		d.now = now
+	}
+}
+
+// WithCredentialsSource sets the credentials source used to add credentials
+// to outgoing discovery requests, as with SetCredentialsSource.
+func WithCredentialsSource(credsSrc auth.CredentialsSource) Option {
+	return func(d *Disco) {
+		d.credsSrc = credsSrc
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		d := NewWithOptions()
+		if got, want := d.timeout, DefaultTimeout; got != want {
+			t.Errorf("wrong timeout %s; want %s", got, want)
+		}
+		if got, want := d.maxRedirects, DefaultMaxRedirects; got != want {
+			t.Errorf("wrong max redirects %d; want %d", got, want)
+		}
+		if got, want := d.maxDocBytes, int64(DefaultMaxDocumentSize); got != want {
+			t.Errorf("wrong max document size %d; want %d", got, want)
+		}
+		if _, ok := d.Transport.(*userAgentRoundTripper); !ok {
+			t.Errorf("wrong default transport %T", d.Transport)
+		}
+	})
+	t.Run("max document size", func(t *testing.T) {
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			resp := []byte(`{"thingy.v1": "http://example.com/foo"}`)
+			w.Header().Add("Content-Type", "application/json")
+			w.Header().Add("Content-Length", strconv.Itoa(len(resp)))
+			w.Write(resp)
+		})
+		defer cleanup()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		// Two discovery objects in the same process can have different
+		// limits, and only the one with the smaller limit fails.
+		small := testDisco(WithMaxDocumentSize(10))
+		if _, err := small.Discover(host); err == nil || !strings.Contains(err.Error(), "too large") {
+			t.Errorf("wrong error %v; want document too large", err)
+		}
+		if _, err := testDisco().Discover(host); err != nil {
+			t.Errorf("unexpected discovery error: %s", err)
+		}
+	})
+	t.Run("max redirects", func(t *testing.T) {
+		portStr1, close1 := testServer(func(w http.ResponseWriter, r *http.Request) {
+			resp := []byte(`{"thingy.v1": "http://example.com/foo"}`)
+			w.Header().Add("Content-Type", "application/json")
+			w.Write(resp)
+		})
+		defer close1()
+		portStr2, close2 := testServer(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://localhost"+portStr1+discoPath, http.StatusFound)
+		})
+		defer close2()
+
+		host, err := svchost.ForComparison("localhost" + portStr2)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		if _, err := testDisco(WithMaxRedirects(0)).Discover(host); err == nil {
+			t.Error("redirect was followed; want error")
+		}
+		if _, err := testDisco(WithMaxRedirects(1)).Discover(host); err != nil {
+			t.Errorf("unexpected discovery error: %s", err)
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		donec := make(chan bool, 1)
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			<-donec
+		})
+		defer cleanup()
+		defer func() { donec <- true }()
+
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+
+		d := testDisco(WithTimeout(10 * time.Millisecond))
+		_, err = d.Discover(host)
+		if _, ok := err.(ErrServiceDiscoveryNetworkRequest); !ok {
+			t.Fatalf("was not an ErrServiceDiscoveryNetworkRequest, got %T %v", err, err)
+		}
+	})
+	t.Run("HTTP client", func(t *testing.T) {
+		transport := testTransport()
+		d := NewWithOptions(WithTimeout(time.Second), WithHTTPClient(&http.Client{
+			Transport: transport,
+			Timeout:   5 * time.Second,
+		}))
+		if d.Transport != transport {
+			t.Errorf("wrong transport %#v; want the client's transport", d.Transport)
+		}
+		if got, want := d.timeout, 5*time.Second; got != want {
+			t.Errorf("wrong timeout %s; want %s", got, want)
+		}
+
+		d = NewWithOptions(WithTimeout(time.Second), WithHTTPClient(&http.Client{}))
+		if d.Transport != http.DefaultTransport {
+			t.Errorf("wrong transport %#v; want http.DefaultTransport", d.Transport)
+		}
+		if got, want := d.timeout, time.Second; got != want {
+			t.Errorf("wrong timeout %s; want %s", got, want)
+		}
+
+		d = NewWithOptions(WithTimeout(time.Second), WithHTTPClient(nil))
+		if d.Transport != http.DefaultTransport {
+			t.Errorf("wrong transport %#v; want http.DefaultTransport", d.Transport)
+		}
+		if got, want := d.timeout, time.Second; got != want {
+			t.Errorf("wrong timeout %s; want %s", got, want)
+		}
+	})
+	t.Run("logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		d := testDisco(WithLogger(log.New(&buf, "", 0)))
+		d.Alias("alias.example.com", "example.com")
+		d.ForceHostServices("example.com", map[string]interface{}{"thingy.v1": 1})
+
+		host, err := d.Discover("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		host.ServiceOAuthClient("thingy.v1") //nolint:errcheck
+
+		got := buf.String()
+		for _, want := range []string{"aliased as alias.example.com", "has Go type int"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("log output does not contain %q\n%s", want, got)
+			}
+		}
+	})
+	t.Run("nil logger", func(t *testing.T) {
+		d := testDisco(WithLogger(nil))
+		d.Alias("alias.example.com", "example.com")
+		d.ForceHostServices("example.com", map[string]interface{}{"thingy.v1": 1})
+
+		host, err := d.Discover("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		host.ServiceOAuthClient("thingy.v1") //nolint:errcheck
+	})
+	t.Run("clock", func(t *testing.T) {
+		fixed := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+		d := NewWithOptions(WithClock(func() time.Time { return fixed }))
+		if got := d.now(); !got.Equal(fixed) {
+			t.Errorf("wrong time %s; want %s", got, fixed)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+const (
+	defaultRetryMinBackoff = 250 * time.Millisecond
+	defaultRetryMaxBackoff = 5 * time.Second
+)
+
+// RetryPolicy describes how a Disco created with the WithRetryPolicy option
+// retries discovery requests that fail for reasons that are likely to be
+// transient: timeouts, connections that are refused or reset, temporary DNS
+// failures, and responses with status 429 (Too Many Requests) or any 5xx
+// status.
+//
+// The delay before each retry grows exponentially from MinBackoff up to
+// MaxBackoff, with random jitter so that many clients don't retry in
+// lockstep. If a response includes a Retry-After header then the delay it
+// asks for is used instead, unless that is longer than MaxBackoff, in which
+// case the request is not retried.
+//
+// A request is never retried if the delay would end after the deadline of
+// the context given to DiscoverContext, or, when several concurrent calls
+// share one request, after the latest of their deadlines.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of requests to make, including the
+	// first. Values less than two disable retries.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry, before jitter is
+	// applied. The default is 250 milliseconds.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum delay before any retry. The default is five
+	// seconds.
+	MaxBackoff time.Duration
+}
+
+// backoff returns the delay before the given retry, counting from one,
+// including random jitter. The result is between half and all of the
+// exponential delay for the retry.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	minBackoff, maxBackoff := p.MinBackoff, p.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = defaultRetryMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	delay := minBackoff
+	for i := 1; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// maxBackoff returns the effective value of p.MaxBackoff.
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return defaultRetryMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+// retryableStatus returns true if a response with the given status code
+// should be retried.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryableError returns true if the given error from an HTTP client
+// describes a problem that might not recur if the request is retried, such
+// as a timeout or a connection that was refused or reset. Problems that
+// retrying can't fix, such as an untrusted certificate, a hostname that
+// doesn't exist, or too many redirects, are not retryable.
+func retryableError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return false
+	}
+	if errors.Is(err, errTooManyRedirects) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter returns the delay requested by the Retry-After header in the
+// given response header, which may be either a number of seconds or an HTTP
+// date, or false if there is no valid Retry-After header.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	raw := header.Get("Retry-After")
+	if raw == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(raw); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// doWithRetry sends the given discovery request using the given client,
+// retrying as described by the receiver's retry policy. It returns the
+// result of the final attempt.
+func (d *Disco) doWithRetry(ctx context.Context, client *http.Client, req *http.Request, hostname svchost.Hostname) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req.Clone(ctx))
+		if attempt >= d.retry.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var delay time.Duration
+		var problem string
+		switch {
+		case err != nil:
+			if !retryableError(err) {
+				return resp, err
+			}
+			delay = d.retry.backoff(attempt)
+			problem = err.Error()
+		case retryableStatus(resp.StatusCode):
+			problem = resp.Status
+			if after, ok := retryAfter(resp.Header, d.now()); ok {
+				if after > d.retry.maxBackoff() {
+					d.logger.Printf("[DEBUG] Not retrying service discovery for %s: server asked to wait %s", hostname, after)
+					return resp, nil
+				}
+				delay = after
+			} else {
+				delay = d.retry.backoff(attempt)
+			}
+		default:
+			return resp, nil
+		}
+
+		if deadline, ok := requestDeadline(ctx); ok && d.now().Add(delay).After(deadline) {
+			d.logger.Printf("[DEBUG] Not retrying service discovery for %s: no time left before deadline", hostname)
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain the body so that the connection can be reused.
+			io.Copy(io.Discard, io.LimitReader(resp.Body, d.maxDocBytes)) //nolint:errcheck
+			resp.Body.Close()
+		}
+		d.logger.Printf("[WARN] Service discovery for %s failed (attempt %d of %d): %s; retrying in %s", hostname, attempt, d.retry.MaxAttempts, problem, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	svchost "github.com/hashicorp/terraform-svchost"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{50, 500 * time.Millisecond, time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			got := policy.backoff(test.retry)
+			if got < test.min || got > test.max {
+				t.Errorf("wrong backoff for retry %d: %s; want between %s and %s", test.retry, got, test.min, test.max)
+			}
+		}
+	}
+
+	if got := (RetryPolicy{}).backoff(1); got < defaultRetryMinBackoff/2 || got > defaultRetryMinBackoff {
+		t.Errorf("wrong default backoff %s", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		"missing":     {"", 0, false},
+		"seconds":     {"120", 2 * time.Minute, true},
+		"negative":    {"-1", 0, false},
+		"date":        {now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		"past date":   {now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		"not a value": {"soon", 0, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			header := http.Header{}
+			if test.value != "" {
+				header.Set("Retry-After", test.value)
+			}
+			got, ok := retryAfter(header, now)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("wrong result %s, %t; want %s, %t", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestDiscoverRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	// testRetryServer returns a test server that responds to each request
+	// using the next of the given handlers, and a pointer to the number of
+	// requests it has received.
+	testRetryServer := func(t *testing.T, handlers ...func(w http.ResponseWriter, r *http.Request)) (svchost.Hostname, *atomic.Int32) {
+		t.Helper()
+		var requests atomic.Int32
+		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+			n := requests.Add(1)
+			handlers[n-1](w, r)
+		})
+		t.Cleanup(cleanup)
+		host, err := svchost.ForComparison("localhost" + portStr)
+		if err != nil {
+			t.Fatalf("test server hostname is invalid: %s", err)
+		}
+		return host, &requests
+	}
+	status := func(code int, header ...string) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i+1 < len(header); i += 2 {
+				w.Header().Set(header[i], header[i+1])
+			}
+			w.WriteHeader(code)
+		}
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}
+	reset := func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack connection: %s", err)
+			return
+		}
+		conn.Close()
+	}
+
+	t.Run("server errors", func(t *testing.T) {
+		host, requests := testRetryServer(t, status(502), status(503), ok)
+		if _, err := testDisco(WithRetryPolicy(policy)).Discover(host); err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if requests.Load() != 3 {
+			t.Errorf("wrong number of requests %d; want 3", requests.Load())
+		}
+	})
+	t.Run("network error", func(t *testing.T) {
+		host, requests := testRetryServer(t, reset, ok)
+		if _, err := testDisco(WithRetryPolicy(policy)).Discover(host); err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if requests.Load() != 2 {
+			t.Errorf("wrong number of requests %d; want 2", requests.Load())
+		}
+	})
+	t.Run("certificate error", func(t *testing.T) {
+		host, requests := testRetryServer(t, ok)
+		var attempts atomic.Int32
+		// This transport doesn't trust the test server's certificate.
+		transport := &http.Transport{}
+		d := NewWithOptions(WithRetryPolicy(policy), WithTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			attempts.Add(1)
+			return transport.RoundTrip(req)
+		})))
+		_, err := d.Discover(host)
+		var tlsErr *tls.CertificateVerificationError
+		if !errors.As(err, &tlsErr) {
+			t.Fatalf("wrong error %v; want certificate verification error", err)
+		}
+		if attempts.Load() != 1 {
+			t.Errorf("wrong number of attempts %d; want 1", attempts.Load())
+		}
+		if requests.Load() != 0 {
+			t.Errorf("wrong number of requests %d; want 0", requests.Load())
+		}
+	})
+	t.Run("attempts exhausted", func(t *testing.T) {
+		host, requests := testRetryServer(t, status(500), status(500), status(500), ok)
+		if _, err := testDisco(WithRetryPolicy(policy)).Discover(host); err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if requests.Load() != 3 {
+			t.Errorf("wrong number of requests %d; want 3", requests.Load())
+		}
+	})
+	t.Run("disabled by default", func(t *testing.T) {
+		host, requests := testRetryServer(t, status(502), ok)
+		if _, err := testDisco().Discover(host); err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if requests.Load() != 1 {
+			t.Errorf("wrong number of requests %d; want 1", requests.Load())
+		}
+	})
+	t.Run("not retryable", func(t *testing.T) {
+		host, requests := testRetryServer(t, status(403), ok)
+		if _, err := testDisco(WithRetryPolicy(policy)).Discover(host); err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if requests.Load() != 1 {
+			t.Errorf("wrong number of requests %d; want 1", requests.Load())
+		}
+	})
+	t.Run("Retry-After", func(t *testing.T) {
+		host, requests := testRetryServer(t, status(429, "Retry-After", "0"), ok)
+		if _, err := testDisco(WithRetryPolicy(policy)).Discover(host); err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		if requests.Load() != 2 {
+			t.Errorf("wrong number of requests %d; want 2", requests.Load())
+		}
+	})
+	t.Run("Retry-After too long", func(t *testing.T) {
+		host, requests := testRetryServer(t, status(503, "Retry-After", "3600"), ok)
+		if _, err := testDisco(WithRetryPolicy(policy)).Discover(host); err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if requests.Load() != 1 {
+			t.Errorf("wrong number of requests %d; want 1", requests.Load())
+		}
+	})
+	t.Run("context deadline", func(t *testing.T) {
+		host, requests := testRetryServer(t, status(502), ok)
+		slow := RetryPolicy{MaxAttempts: 3, MinBackoff: time.Minute, MaxBackoff: time.Hour}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		if _, err := testDisco(WithRetryPolicy(slow)).DiscoverContext(ctx, host); err == nil {
+			t.Fatal("completed successfully; want error")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("waited %s for a retry that could not complete before the deadline", elapsed)
+		}
+		if requests.Load() != 1 {
+			t.Errorf("wrong number of requests %d; want 1", requests.Load())
+		}
+	})
+}
+
+func TestRetryableError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"connection refused": {
+			&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+			true,
+		},
+		"connection reset": {
+			&url.Error{Op: "Get", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}},
+			true,
+		},
+		"unexpected EOF": {
+			&url.Error{Op: "Get", Err: io.EOF},
+			true,
+		},
+		"timeout": {
+			&url.Error{Op: "Get", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}},
+			true,
+		},
+		"temporary DNS failure": {
+			&url.Error{Op: "Get", Err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}},
+			true,
+		},
+		"host not found": {
+			&url.Error{Op: "Get", Err: &net.DNSError{Err: "no such host", IsNotFound: true}},
+			false,
+		},
+		"too many redirects": {
+			&url.Error{Op: "Get", Err: errTooManyRedirects},
+			false,
+		},
+		"untrusted certificate": {
+			&url.Error{Op: "Get", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}},
+			false,
+		},
+		"other": {
+			&url.Error{Op: "Get", Err: errors.New("unsupported protocol scheme")},
+			false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := retryableError(test.err); got != test.want {
+				t.Errorf("wrong result %t; want %t", got, test.want)
+			}
+		})
+	}
+}
+
+// roundTripFunc is an http.RoundTripper implemented by a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,375 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// ServiceID is a parsed service identifier from a discovery document, such
+// as "modules.v1", which has the name "modules" and version 1.
+type ServiceID struct {
+	Name    string
+	Version *version.Version
+}
+
+// String returns the service identifier in the form used in discovery
+// documents.
+func (id ServiceID) String() string {
+	return id.Name + "." + id.Version.Original()
+}
+
+// Services returns the identifiers of all of the services that the host
+// provides, sorted by name and then by version. Properties of the discovery
+// document that are not valid service identifiers are ignored.
+//
+// The result is empty for a host that provides no services.
+func (h *Host) Services() []ServiceID {
+	if h == nil {
+		return nil
+	}
+
+	var ids []ServiceID
+	for raw := range h.services {
+		name, ver, err := parseServiceID(raw)
+		if err != nil {
+			h.logf("[DEBUG] Ignoring invalid service identifier %q in discovery document for %s: %s", raw, h.hostname, err)
+			continue
+		}
+		ids = append(ids, ServiceID{Name: name, Version: ver})
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Name != ids[j].Name {
+			return ids[i].Name < ids[j].Name
+		}
+		return ids[i].Version.LessThan(ids[j].Version)
+	})
+	return ids
+}
+
+// SupportedVersions returns the versions of the given service, such as
+// "modules", that the host provides, in increasing order. The result is
+// empty if the host doesn't provide the service at all.
+func (h *Host) SupportedVersions(service string) []*version.Version {
+	var versions []*version.Version
+	for _, id := range h.Services() {
+		if id.Name == service {
+			versions = append(versions, id.Version)
+		}
+	}
+	return versions
+}
+
+// NegotiatedService is the result of Host.NegotiateService.
+type NegotiatedService struct {
+	// ID is the identifier of the negotiated service version.
+	ID ServiceID
+
+	// URL is the URL of the negotiated service version, resolved as for
+	// ServiceURL.
+	URL *url.URL
+
+	// Offered is all of the versions of the service that the host provides,
+	// in increasing order, for use in diagnostics.
+	Offered []*version.Version
+}
+
+// NegotiateService selects the highest version of the given service, such as
+// "modules", that both the host and the caller support, and returns it along
+// with its URL.
+//
+// Each of the supported arguments is either a version in the form used in
+// service identifiers, such as "v1", which matches only that exact version,
+// or a go-version constraint string such as ">= 1.1, < 3". A version the host
+// offers is acceptable if it matches any of them.
+//
+// If the host doesn't provide the service at all, the error is an
+// *ErrServiceNotProvided. If it provides the service but none of the
+// acceptable versions, the error is an *ErrNoSupportedVersion listing the
+// versions it does provide.
+func (h *Host) NegotiateService(service string, supported ...string) (*NegotiatedService, error) {
+	var constraints []version.Constraints
+	for _, raw := range supported {
+		c, err := version.NewConstraint(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid supported version %q for service %s: %w", raw, service, err)
+		}
+		constraints = append(constraints, c)
+	}
+
+	// No services supported for an empty Host.
+	if h == nil || h.services == nil {
+		return nil, &ErrServiceNotProvided{service: service}
+	}
+
+	offered := h.SupportedVersions(service)
+	if len(offered) == 0 {
+		return nil, &ErrServiceNotProvided{hostname: h.hostname, service: service}
+	}
+
+	latest := latestVersion(offered, func(v *version.Version) bool {
+		for _, c := range constraints {
+			if c.Check(v) {
+				return true
+			}
+		}
+		return false
+	})
+	if latest == nil {
+		return nil, &ErrNoSupportedVersion{
+			hostname:  h.hostname,
+			Service:   service,
+			Supported: supported,
+			Offered:   offered,
+		}
+	}
+
+	id := ServiceID{Name: service, Version: latest}
+	u, err := h.ServiceURL(id.String())
+	if err != nil {
+		return nil, err
+	}
+	return &NegotiatedService{ID: id, URL: u, Offered: offered}, nil
+}
+
+// ErrNoSupportedVersion is returned by Host.NegotiateService when the host
+// provides a service, but none of the versions the caller supports.
+type ErrNoSupportedVersion struct {
+	hostname string
+
+	// Service is the name of the service being negotiated.
+	Service string
+
+	// Supported is the versions and version constraints that the caller
+	// supports, as given to NegotiateService.
+	Supported []string
+
+	// Offered is all of the versions of the service that the host provides,
+	// in increasing order.
+	Offered []*version.Version
+}
+
+// Error returns a customized error message.
+func (e *ErrNoSupportedVersion) Error() string {
+	offered := make([]string, len(e.Offered))
+	for i, v := range e.Offered {
+		offered[i] = v.Original()
+	}
+	supported := "none"
+	if len(e.Supported) != 0 {
+		supported = strings.Join(e.Supported, "; ")
+	}
+	return fmt.Sprintf(
+		"host %s does not support any compatible version of %s (offers %s; supported %s)",
+		e.hostname, e.Service, strings.Join(offered, ", "), supported,
+	)
+}
+
+// latestVersion returns the highest of the given versions for which accept
+// returns true, or nil if there is none. A nil accept function accepts every
+// version.
+func latestVersion(versions []*version.Version, accept func(*version.Version) bool) *version.Version {
+	var latest *version.Version
+	for _, v := range versions {
+		if accept != nil && !accept(v) {
+			continue
+		}
+		if latest == nil || latest.LessThan(v) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// DecodeService decodes the value of the given service identifier, which
+// should be of the form "servicename.vN", from the host's discovery document
+// into the value pointed to by target, using the same rules as
+// encoding/json.Unmarshal.
+//
+// This is for services whose definition is not just a URL, for which
+// ServiceURL and ServiceOAuthClient are more convenient. Relative URLs in the
+// decoded value are not resolved; use ServiceURL's rules, resolving against
+// the discovery document URL, if the service's specification calls for that.
+//
+// If the host doesn't provide the service, the error is an
+// *ErrServiceNotProvided or *ErrVersionNotSupported as for ServiceURL. If
+// the value can't be decoded into target, the error is an
+// *ErrServiceDecode describing where in the value the problem is.
+func (h *Host) DecodeService(id string, target interface{}) error {
+	svc, ver, err := parseServiceID(id)
+	if err != nil {
+		return err
+	}
+
+	// No services supported for an empty Host.
+	if h == nil || h.services == nil {
+		return &ErrServiceNotProvided{service: svc}
+	}
+
+	raw, ok := h.services[id]
+	if !ok {
+		if len(h.SupportedVersions(svc)) != 0 {
+			return &ErrVersionNotSupported{
+				hostname: h.hostname,
+				service:  svc,
+				version:  ver.Original(),
+			}
+		}
+		return &ErrServiceNotProvided{hostname: h.hostname, service: svc}
+	}
+
+	// The document was decoded without knowing the caller's types, so we
+	// round-trip the value through JSON to decode it into the target.
+	src, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("failed to prepare service %s for decoding: %w", id, err)
+	}
+	err = json.Unmarshal(src, target)
+	if err == nil {
+		return nil
+	}
+
+	decodeErr := &ErrServiceDecode{
+		hostname: h.hostname,
+		Service:  id,
+		Path:     "$",
+		Err:      err,
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		decodeErr.Path = jsonPathAtOffset(src, typeErr.Offset)
+	}
+	return decodeErr
+}
+
+// ErrServiceDecode is returned by Host.DecodeService when the value of a
+// service can't be decoded into the given target.
+type ErrServiceDecode struct {
+	hostname string
+
+	// Service is the service identifier whose value couldn't be decoded.
+	Service string
+
+	// Path is the location of the problem within the service's value, as
+	// a JSONPath expression such as "$.ports[1]". It is "$", meaning the
+	// whole value, if the location is unknown.
+	Path string
+
+	// Err is the underlying error from encoding/json.
+	Err error
+}
+
+// Error returns a customized error message.
+func (e *ErrServiceDecode) Error() string {
+	msg := e.Err.Error()
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(e.Err, &typeErr) {
+		msg = fmt.Sprintf("cannot use %s value as %s", typeErr.Value, typeErr.Type)
+	}
+	if e.hostname == "" {
+		return fmt.Sprintf("invalid definition for service %s at %s: %s", e.Service, e.Path, msg)
+	}
+	return fmt.Sprintf("host %s has an invalid definition for service %s at %s: %s", e.hostname, e.Service, e.Path, msg)
+}
+
+func (e *ErrServiceDecode) Unwrap() error {
+	return e.Err
+}
+
+// jsonPathFrame tracks the position within an object or array while
+// jsonPathAtOffset scans a JSON document.
+type jsonPathFrame struct {
+	object  bool
+	wantKey bool
+	key     string
+	index   int
+}
+
+// next advances the frame past a value that it contains.
+func (f *jsonPathFrame) next() {
+	if f.object {
+		f.wantKey = true
+	} else {
+		f.index++
+	}
+}
+
+// jsonIdentifierPattern matches object keys that can be written in
+// dot notation in a JSONPath expression.
+var jsonIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonPathAtOffset returns a JSONPath expression for the value in the given
+// valid JSON document that encoding/json had just begun or finished reading
+// at the given byte offset, as reported in a json.UnmarshalTypeError. It
+// returns "$" if the offset is not within any nested value.
+func jsonPathAtOffset(src []byte, offset int64) string {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	var stack []jsonPathFrame
+
+	path := func() string {
+		var b strings.Builder
+		b.WriteString("$")
+		for _, f := range stack {
+			switch {
+			case !f.object:
+				b.WriteString("[" + strconv.Itoa(f.index) + "]")
+			case jsonIdentifierPattern.MatchString(f.key):
+				b.WriteString("." + f.key)
+			default:
+				b.WriteString("[" + strconv.Quote(f.key) + "]")
+			}
+		}
+		return b.String()
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "$"
+		}
+		var top *jsonPathFrame
+		if len(stack) != 0 {
+			top = &stack[len(stack)-1]
+		}
+
+		if top != nil && top.object && top.wantKey {
+			if key, ok := tok.(string); ok {
+				top.key = key
+				top.wantKey = false
+				continue
+			}
+		}
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) != 0 {
+				stack[len(stack)-1].next()
+			}
+			continue
+		}
+
+		// Any other token begins a value.
+		if dec.InputOffset() >= offset {
+			return path()
+		}
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, jsonPathFrame{object: true, wantKey: true})
+		case json.Delim('['):
+			stack = append(stack, jsonPathFrame{})
+		default:
+			if top != nil {
+				top.next()
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright IBM Corp. 2017, 2025
+
+package disco
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHostServices(t *testing.T) {
+	host := &Host{
+		hostname: "example.com",
+		services: map[string]interface{}{
+			"providers.v1":  "/providers/",
+			"modules.v1":    "/modules/",
+			"tfe.v2.2":      "/api/v2/",
+			"tfe.v10":       "/api/v10/",
+			"tfe.v2":        "/api/v2/",
+			"not-a-service": "ignored",
+			"bad.version":   "ignored",
+		},
+	}
+
+	var got []string
+	for _, id := range host.Services() {
+		got = append(got, id.String())
+	}
+	want := []string{"modules.v1", "providers.v1", "tfe.v2", "tfe.v2.2", "tfe.v10"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong services\n%s", diff)
+	}
+
+	got = nil
+	for _, v := range host.SupportedVersions("tfe") {
+		got = append(got, v.Original())
+	}
+	want = []string{"v2", "v2.2", "v10"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong versions\n%s", diff)
+	}
+
+	if got := host.SupportedVersions("nothing"); len(got) != 0 {
+		t.Errorf("wrong versions for unsupported service %v; want none", got)
+	}
+	if got := (*Host)(nil).Services(); got != nil {
+		t.Errorf("wrong services for nil host %v; want nil", got)
+	}
+}
+
+func TestHostDecodeService(t *testing.T) {
+	type endpoint struct {
+		URL   string `json:"url"`
+		Ports []int  `json:"ports"`
+	}
+	type thingy struct {
+		Name      string              `json:"name"`
+		Endpoints []endpoint          `json:"endpoints"`
+		Labels    map[string]endpoint `json:"labels"`
+	}
+
+	host := &Host{
+		hostname: "example.com",
+		services: map[string]interface{}{
+			"thingy.v1": map[string]interface{}{
+				"name": "thingy",
+				"endpoints": []interface{}{
+					map[string]interface{}{"url": "https://example.com/", "ports": []interface{}{80, 443}},
+				},
+			},
+			"thingy.v2": map[string]interface{}{
+				"endpoints": []interface{}{
+					map[string]interface{}{"url": "https://example.com/"},
+					map[string]interface{}{"url": "https://example.net/", "ports": []interface{}{80, "443"}},
+				},
+			},
+			"thingy.v3": map[string]interface{}{
+				"labels": map[string]interface{}{
+					"not an identifier": map[string]interface{}{"url": true},
+				},
+			},
+			"thingy.v4": "just a string",
+		},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var got thingy
+		if err := host.DecodeService("thingy.v1", &got); err != nil {
+			t.Fatal(err)
+		}
+		want := thingy{
+			Name:      "thingy",
+			Endpoints: []endpoint{{URL: "https://example.com/", Ports: []int{80, 443}}},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong result\n%s", diff)
+		}
+	})
+
+	errorTests := map[string]string{
+		"thingy.v2": "$.endpoints[1].ports[1]",
+		"thingy.v3": `$.labels["not an identifier"].url`,
+		"thingy.v4": "$",
+	}
+	for id, wantPath := range errorTests {
+		t.Run(id, func(t *testing.T) {
+			var got thingy
+			err := host.DecodeService(id, &got)
+			var decodeErr *ErrServiceDecode
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("wrong error %v; want ErrServiceDecode", err)
+			}
+			if decodeErr.Path != wantPath {
+				t.Errorf("wrong path %q; want %q", decodeErr.Path, wantPath)
+			}
+		})
+	}
+
+	t.Run("not provided", func(t *testing.T) {
+		var got thingy
+		err := host.DecodeService("wotsit.v1", &got)
+		if _, ok := err.(*ErrServiceNotProvided); !ok {
+			t.Errorf("wrong error %v; want ErrServiceNotProvided", err)
+		}
+		err = host.DecodeService("thingy.v9", &got)
+		if _, ok := err.(*ErrVersionNotSupported); !ok {
+			t.Errorf("wrong error %v; want ErrVersionNotSupported", err)
+		}
+	})
+}
+
+func TestHostNegotiateService(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/disco/foo.json")
+	host := &Host{
+		discoURL: baseURL,
+		hostname: "example.com",
+		services: map[string]interface{}{
+			"thingy.v1":   "/api/v1/",
+			"thingy.v2":   "/api/v2/",
+			"thingy.v2.1": "https://thingy.example.net/v2.1/",
+			"thingy.v3":   "/api/v3/",
+			"wotsit.v1":   "/wotsit/",
+		},
+	}
+
+	tests := map[string]struct {
+		supported []string
+		wantID    string
+		wantURL   string
+	}{
+		"exact version": {
+			[]string{"v2"},
+			"thingy.v2",
+			"https://example.com/api/v2/",
+		},
+		"several exact versions": {
+			[]string{"v1", "v2"},
+			"thingy.v2",
+			"https://example.com/api/v2/",
+		},
+		"constraint": {
+			[]string{">= 2, < 3"},
+			"thingy.v2.1",
+			"https://thingy.example.net/v2.1/",
+		},
+		"version and constraint": {
+			[]string{"v1", "~> 3.0"},
+			"thingy.v3",
+			"https://example.com/api/v3/",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := host.NegotiateService("thingy", test.supported...)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := got.ID.String(); got != test.wantID {
+				t.Errorf("wrong ID %q; want %q", got, test.wantID)
+			}
+			if got := got.URL.String(); got != test.wantURL {
+				t.Errorf("wrong URL %q; want %q", got, test.wantURL)
+			}
+			var offered []string
+			for _, v := range got.Offered {
+				offered = append(offered, v.Original())
+			}
+			if diff := cmp.Diff([]string{"v1", "v2", "v2.1", "v3"}, offered); diff != "" {
+				t.Errorf("wrong offered versions\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("no compatible version", func(t *testing.T) {
+		_, err := host.NegotiateService("thingy", "v4", ">= 5")
+		var noVersionErr *ErrNoSupportedVersion
+		if !errors.As(err, &noVersionErr) {
+			t.Fatalf("wrong error %v; want ErrNoSupportedVersion", err)
+		}
+		if got, want := len(noVersionErr.Offered), 4; got != want {
+			t.Errorf("wrong number of offered versions %d; want %d", got, want)
+		}
+		want := "host example.com does not support any compatible version of thingy (offers v1, v2, v2.1, v3; supported v4; >= 5)"
+		if got := err.Error(); got != want {
+			t.Errorf("wrong error message\ngot:  %s\nwant: %s", got, want)
+		}
+	})
+
+	t.Run("not provided", func(t *testing.T) {
+		_, err := host.NegotiateService("doodad", "v1")
+		if _, ok := err.(*ErrServiceNotProvided); !ok {
+			t.Errorf("wrong error %v; want ErrServiceNotProvided", err)
+		}
+	})
+
+	t.Run("invalid constraint", func(t *testing.T) {
+		_, err := host.NegotiateService("thingy", "not a version")
+		if err == nil {
+			t.Fatal("succeeded; want error")
+		}
+	})
+}